@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Shahlojon/wallet/pkg/wallet"
+)
+
+//analytics - печатает таблицу расходов из дампов в каталоге:
+//	wallet analytics -dir . -by category
+func analytics(args []string) {
+	flags := flag.NewFlagSet("analytics", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory with dumps")
+	by := flags.String("by", "category", "grouping: category, parent, account, day, week, month")
+	goroutines := flags.Int("goroutines", 4, "number of goroutines")
+	flags.Parse(args)
+
+	svc := &wallet.Service{}
+	svc.SetProgress(progressBar(os.Stderr))
+	err := svc.Import(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stats, err := svc.SpendingAnalytics(wallet.PaymentQuery{}, wallet.GroupBy(*by), *goroutines)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "KEY\tTOTAL\tCOUNT\tAVERAGE\tP50\tP90\tP99\t")
+	for _, stat := range stats {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t\n",
+			stat.Key, stat.Total, stat.Count, stat.Average, stat.P50, stat.P90, stat.P99)
+	}
+	w.Flush()
+}
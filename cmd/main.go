@@ -3,13 +3,29 @@ package main
 import (
 	"github.com/Shahlojon/wallet/pkg/types"
 	//"path/filepath"
-	// "os"
+	"os"
 	"log"
 	"github.com/Shahlojon/wallet/pkg/wallet"
 	// "fmt"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "restore":
+			restore(os.Args[2:])
+			return
+		case "analytics":
+			analytics(os.Args[2:])
+			return
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "settle":
+			settle(os.Args[2:])
+			return
+		}
+	}
 	//fmt.Println("hello")
 //	svc :=&wallet.Service{}
 	//svc.RegisterAccount("+992000000001")
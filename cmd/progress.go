@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/wallet"
+)
+
+//progressBarWidth - ширина полосы прогресса в символах
+const progressBarWidth = 30
+
+//progressBar - печатает прогресс долгой операции одной перерисовываемой строкой:
+//	export [#########.....................]  30% 300/1000 ETA 2s
+func progressBar(w io.Writer) wallet.ProgressFunc {
+	return func(report wallet.ProgressReport) {
+		filled := int(report.Percent) * progressBarWidth / 100
+		bar := strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled)
+		fmt.Fprintf(w, "\r%s [%s] %3.0f%% %d/%d ETA %s ", report.Operation, bar, report.Percent,
+			report.Processed, report.Total, report.ETA.Round(time.Second))
+		if report.Done {
+			fmt.Fprintln(w)
+		}
+	}
+}
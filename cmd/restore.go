@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/wallet"
+)
+
+//restore - показывает аккаунт и историю его платежей на заданный момент времени:
+//	wallet restore -dir data -at 2020-11-20T14:00:00+05:00 -account 42
+func restore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory with journal.dump and snapshots")
+	at := flags.String("at", "", "point in time, RFC3339")
+	accountID := flags.Int64("account", 0, "account id")
+	flags.Parse(args)
+
+	moment, err := time.Parse(time.RFC3339, *at)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	svc, err := wallet.RestoreAt(*dir, moment)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	account, err := svc.FindAccountByID(*accountID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("account %d, phone %s, balance %d\n", account.ID, account.Phone, account.Balance)
+
+	payments, err := svc.ExportAccountHistory(account.ID)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	for _, payment := range payments {
+		fmt.Printf("%s;%d;%s;%s\n", payment.ID, payment.Amount, payment.Category, payment.Status)
+	}
+}
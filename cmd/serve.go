@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+	"github.com/Shahlojon/wallet/pkg/wallet"
+)
+
+//server - HTTP-доступ к долгим операциям сервиса с прогрессом в виде server-sent events
+type server struct {
+	mu  sync.Mutex //Service не рассчитан на параллельные вызовы
+	svc *wallet.Service
+	dir string
+}
+
+//serve - загружает дампы из каталога и отдаёт долгие операции по HTTP:
+//	wallet serve -dir . -addr :8080
+//	curl -N localhost:8080/sum
+//	curl -N -X POST localhost:8080/export
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory with dumps")
+	addr := flags.String("addr", ":8080", "listen address")
+	flags.Parse(args)
+
+	srv := &server{svc: &wallet.Service{}, dir: *dir}
+	err := srv.svc.Import(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/export", srv.handleExport)
+	mux.HandleFunc("/sum", srv.handleSum)
+	mux.HandleFunc("/filter", srv.handleFilter)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+//stream - выполняет операцию, отправляя каждый отчёт о прогрессе событием progress,
+//а результат - событием result (или error)
+func (s *server) stream(w http.ResponseWriter, r *http.Request, run func(ctx context.Context) (interface{}, error)) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	send := func(event string, data interface{}) {
+		body, err := json.Marshal(data)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+		flusher.Flush()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.svc.SetProgress(func(report wallet.ProgressReport) {
+		send("progress", report)
+	})
+	defer s.svc.SetProgress(nil)
+
+	result, err := run(r.Context())
+	if err != nil {
+		send("error", err.Error())
+		return
+	}
+	send("result", result)
+}
+
+//handleExport - перезаписывает дампы в каталоге, поэтому выполняется только по POST
+func (s *server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.stream(w, r, func(ctx context.Context) (interface{}, error) {
+		return s.dir, s.svc.Export(s.dir)
+	})
+}
+
+func (s *server) handleSum(w http.ResponseWriter, r *http.Request) {
+	s.stream(w, r, func(ctx context.Context) (interface{}, error) {
+		return s.svc.SumPaymentsContext(ctx, wallet.SpentOptions)
+	})
+}
+
+func (s *server) handleFilter(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(r.URL.Query().Get("account"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid account", http.StatusBadRequest)
+		return
+	}
+	s.stream(w, r, func(ctx context.Context) (interface{}, error) {
+		return s.svc.FilterPaymentsContext(ctx, func(payment types.Payment) bool {
+			return payment.AccountID == accountID
+		}, wallet.ScanOptions{})
+	})
+}
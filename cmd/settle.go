@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/wallet"
+)
+
+//settle - закрывает день, печатает итоги и сверяет их с выпиской банка:
+//	wallet settle -dir data -day 2020-11-20 -statement bank.csv
+//Закрытый день сохраняется обратно в каталог, если не указан -dry-run.
+func settle(args []string) {
+	flags := flag.NewFlagSet("settle", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory with dumps")
+	dayFlag := flags.String("day", "", "day to close, 2006-01-02 (default: yesterday)")
+	statementPath := flags.String("statement", "", "CSV statement with id, amount and status columns")
+	dryRun := flags.Bool("dry-run", false, "only show totals, do not close the day")
+	flags.Parse(args)
+
+	day := time.Now().AddDate(0, 0, -1)
+	if *dayFlag != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", *dayFlag, time.Local)
+		if err != nil {
+			log.Fatal(err)
+		}
+		day = parsed
+	}
+
+	svc := &wallet.Service{}
+	err := svc.Import(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	settlement := svc.DaySettlement(day)
+	if !*dryRun {
+		settlement, err = svc.CloseDay(day)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = svc.Export(*dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = svc.FlushJournal(*dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Printf("day %s, completed %d\n", settlement.Day, settlement.Completed)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, group := range []struct {
+		name   string
+		totals []wallet.SettlementTotal
+	}{
+		{"STATUS", settlement.ByStatus},
+		{"CATEGORY", settlement.ByCategory},
+		{"MERCHANT", settlement.ByMerchant},
+	} {
+		fmt.Fprintf(w, "%s\tCOUNT\tTOTAL\t\n", group.name)
+		for _, total := range group.totals {
+			fmt.Fprintf(w, "%s\t%d\t%d\t\n", total.Key, total.Count, total.Total)
+		}
+		fmt.Fprintln(w, "\t\t\t")
+	}
+	w.Flush()
+
+	if *statementPath == "" {
+		return
+	}
+	file, err := os.Open(*statementPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	statement, err := wallet.ParseStatement(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result := svc.Reconcile(day, statement)
+	fmt.Printf("matched %d, missing in wallet %d, missing in statement %d, mismatched %d\n",
+		len(result.Matched), len(result.MissingInWallet), len(result.MissingInStatement), len(result.Mismatched))
+	for _, group := range []struct {
+		name  string
+		items []wallet.ReconciliationItem
+	}{
+		{"missing in wallet", result.MissingInWallet},
+		{"missing in statement", result.MissingInStatement},
+		{"mismatched", result.Mismatched},
+	} {
+		for _, item := range group.items {
+			fmt.Printf("%s;%s;%d;%s;%d;%s;%s\n", group.name, item.PaymentID,
+				item.WalletAmount, item.WalletStatus, item.StatementAmount, item.StatementStatus, item.Reason)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 //Money представляет собой денежную сумму в минимальных единицах (центы, копейки, дирамы и т.д)
 type Money int64
 
@@ -15,6 +17,11 @@ const (
 	PaymentStatusOk PaymentStatus  ="OK"
 	PaymentStatusFail PaymentStatus  = "FAIL"
 	PaymentStatusInProgress PaymentStatus  = "INPROGRESS"
+	PaymentStatusAuthorized PaymentStatus = "AUTHORIZED" //Средства зарезервированы и ждут списания (Capture)
+	PaymentStatusVoid PaymentStatus = "VOID" //Резерв снят без списания (Void или истёк срок)
+	PaymentStatusRefund PaymentStatus = "REFUND" //Возврат части или всей суммы платежа ParentID
+	PaymentStatusCashback PaymentStatus = "CASHBACK" //Кешбэк, начисленный за платёж ParentID
+	PaymentStatusClawback PaymentStatus = "CLAWBACK" //Кешбэк, отменённый вместе с платежом ParentID
 )
 
 //Payment представляет информацию о платеже
@@ -24,6 +31,21 @@ type Payment struct{
 	Amount Money
 	Category PaymentCategory
 	Status PaymentStatus
+	CreatedAt time.Time
+	UpdatedAt time.Time //Время последней смены статуса
+	ExpiresAt time.Time //Для AUTHORIZED - когда резерв будет снят автоматически
+	ParentID string //Для связанных записей (например, возвратов) - исходный платёж
+	Reason string
+	ToAccountID int64 //Для переводов - счёт получателя, 0 - не перевод
+	MerchantID int64 //Продавец, которому адресован платёж, 0 - без продавца
+}
+
+//Deposit представляет собой пополнение счёта
+type Deposit struct {
+	ID string
+	AccountID int64
+	Amount Money
+	CreatedAt time.Time
 }
 
 type Phone string
@@ -33,12 +55,145 @@ type Account struct {
 	ID int64
 	Phone Phone
 	Balance Money
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Overdraft Money //Одобренная кредитная линия: баланс может опускаться до -Overdraft
+	OverdraftGrace time.Duration //Беспроцентный период для отрицательного баланса
+	OverdraftSince time.Time //С какого момента баланс отрицательный, нулевое - баланс не отрицательный
+	Hold Money //Зарезервировано авторизованными платежами, но ещё не списано
+	Status AccountStatus //Пустое - ACTIVE (счета из старых дампов)
+	StatusReason string //Причина последней смены состояния
+	Email string //Дополнительные идентификаторы, пустое - не задан
+	ExternalID string //Идентификатор клиента во внешней системе
+	PreviousPhones []PhoneChange //Прежние номера, от старых к новым
+	Profile Profile
+	Turnover Money //Оборот (пополнения и списания) за календарный месяц TurnoverMonth
+	TurnoverMonth time.Time //Первый день месяца, к которому относится Turnover
+}
+
+//VerificationTier представляет собой уровень идентификации клиента
+type VerificationTier string
+
+//Предопределенные уровни идентификации, от низшего к высшему
+const (
+	TierUnverified VerificationTier = "UNVERIFIED"
+	TierBasic VerificationTier = "BASIC" //Упрощённая идентификация
+	TierFull VerificationTier = "FULL" //Полная идентификация по документу
+)
+
+//Profile представляет собой данные клиента, владельца счёта
+type Profile struct {
+	Name string
+	DocumentNumber string
+	Tier VerificationTier //Пустое - UNVERIFIED
+}
+
+//TierLimits представляет собой ограничения кошельков одного уровня идентификации.
+//Нулевое значение - без ограничения.
+type TierLimits struct {
+	MaxBalance Money
+	MonthlyTurnover Money
+}
+
+//PhoneChange представляет собой прежний номер счёта и момент, когда он был заменён
+type PhoneChange struct {
+	Phone Phone
+	ChangedAt time.Time
+}
+
+//AccountStatus представляет собой состояние счёта
+type AccountStatus string
+
+//Предопределенные состояния счёта
+const (
+	AccountStatusActive AccountStatus = "ACTIVE"
+	AccountStatusFrozen AccountStatus = "FROZEN" //Операции запрещены до разморозки
+	AccountStatusClosed AccountStatus = "CLOSED" //Операции запрещены навсегда, счёт остаётся в дампах
+)
+
+//Available - сколько можно потратить из собственных средств: учётный баланс за вычетом резервов
+func (a *Account) Available() Money {
+	return a.Balance - a.Hold
 }
 
 type Favorite struct {
 	ID string 
 	AccountID int64
 	Name string
-	Amount Money
+	Amount Money //Сумма по умолчанию, 0 - сумма указывается при каждом платеже
+	Category PaymentCategory
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+//Limits представляет собой ограничения расходов аккаунта. Нулевое значение - без ограничения.
+type Limits struct {
+	AccountID int64
+	MaxPayment Money //Максимальная сумма одного платежа
+	Daily Money //Максимум расходов за календарный день
+	Monthly Money //Максимум расходов за календарный месяц
+	Categories map[PaymentCategory]Money //Максимум расходов за месяц по категориям
+}
+
+//Schedule представляет собой расписание автоматических платежей по избранному
+type Schedule struct {
+	FavoriteID string
+	Spec string //daily 09:30, weekly mon 09:30, monthly 15 09:30 или cron 30 9 * * *
+	NextRun time.Time //Когда платёж должен быть выполнен
+	Attempts int //Сколько раз подряд не хватило баланса для текущего выполнения
+}
+
+//ExecutionStatus представляет собой результат выполнения платежа по расписанию
+type ExecutionStatus string
+
+//Предопределенные результаты выполнения
+const (
+	ExecutionStatusOk ExecutionStatus = "OK"
+	ExecutionStatusRetry ExecutionStatus = "RETRY" //Не хватило баланса, будет повтор
+	ExecutionStatusSkipped ExecutionStatus = "SKIPPED" //Повторы закончились, выполнение пропущено
+	ExecutionStatusFail ExecutionStatus = "FAIL" //Платёж невозможен по другой причине
+)
+
+//Execution представляет информацию о выполнении платежа по расписанию
+type Execution struct {
+	FavoriteID string
+	ScheduledAt time.Time
+	ExecutedAt time.Time
+	Status ExecutionStatus
+	PaymentID string //Заполнен для ExecutionStatusOk
+	Error string
+}
+
+//FeeRule представляет собой правило расчёта комиссии. Пустые Category и Tier - правило
+//для любой категории и любого уровня. Комиссия = Fixed + Amount * Percent / 10000,
+//но не меньше Min и не больше Max (0 - без ограничения сверху).
+type FeeRule struct {
+	Category PaymentCategory
+	Tier VerificationTier
+	Percent int64 //В сотых долях процента: 100 - 1%
+	Fixed Money
+	Min Money
+	Max Money
+}
+
+//CashbackRule представляет собой правило кешбэка для категории платежей
+type CashbackRule struct {
 	Category PaymentCategory
-}
\ No newline at end of file
+	Percent int64 //В сотых долях процента: 100 - 1%
+	MonthlyCap Money //Максимум кешбэка по категории за календарный месяц, 0 - без ограничения
+}
+
+//Category представляет собой категорию платежей из справочника
+type Category struct {
+	Code PaymentCategory //Каноническое название, которое хранится в платежах
+	Name string //Название для отображения
+	Parent PaymentCategory //Родительская категория, пустое - категория верхнего уровня
+	Aliases []PaymentCategory //Другие написания (в том числе ошибочные), которые приводятся к Code
+}
+
+//Merchant представляет собой продавца - получателя платежей
+type Merchant struct {
+	ID int64
+	Name string
+	Category PaymentCategory //Категория платежей продавцу по умолчанию
+	SettlementAccountID int64 //Счёт, на который продавцу перечисляется выручка
+}
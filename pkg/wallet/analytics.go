@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+var ErrInvalidGroupBy = errors.New("invalid grouping")
+
+//GroupBy представляет собой способ группировки платежей в аналитике
+type GroupBy string
+
+//Поддерживаемые группировки
+const (
+	GroupByCategory GroupBy = "category"
+	GroupByAccount  GroupBy = "account"
+	GroupByDay      GroupBy = "day"
+	GroupByWeek     GroupBy = "week"
+	GroupByMonth    GroupBy = "month"
+	//GroupByParent - по категориям верхнего уровня справочника категорий
+	GroupByParent GroupBy = "parent"
+)
+
+//SpendingStat - статистика расходов по одной группе
+type SpendingStat struct {
+	Key     string
+	Total   types.Money
+	Count   int
+	Average types.Money
+	P50     types.Money
+	P90     types.Money
+	P99     types.Money
+}
+
+//groupKey - возвращает ключ группы, в которую попадает платёж.
+//Синонимы категорий из справочника categories сводятся к коду категории.
+func groupKey(payment *types.Payment, groupBy GroupBy, categories map[types.PaymentCategory]*types.Category) string {
+	switch groupBy {
+	case GroupByCategory:
+		return string(canonicalCategory(categories, payment.Category))
+	case GroupByParent:
+		return string(rootCategory(categories, payment.Category))
+	case GroupByAccount:
+		return strconv.FormatInt(payment.AccountID, 10)
+	case GroupByDay:
+		return payment.CreatedAt.Format("2006-01-02")
+	case GroupByWeek:
+		year, week := payment.CreatedAt.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	default:
+		return payment.CreatedAt.Format("2006-01")
+	}
+}
+
+//percentile - значение перцентиля p (0-100) по методу ближайшего ранга, amounts отсортированы
+func percentile(amounts []types.Money, p int) types.Money {
+	if len(amounts) == 0 {
+		return 0
+	}
+	rank := (p*len(amounts) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return amounts[rank-1]
+}
+
+//SpendingAnalytics - считает суммы, количество, среднее и перцентили расходов,
+//сгруппированные по groupBy. Учитываются платежи, подходящие под query, кроме отменённых.
+//Как и SumPayments, платежи делятся на goroutines частей, которые обрабатываются параллельно.
+func (s *Service) SpendingAnalytics(query PaymentQuery, groupBy GroupBy, goroutines int) ([]SpendingStat, error) {
+	switch groupBy {
+	case GroupByCategory, GroupByParent, GroupByAccount, GroupByDay, GroupByWeek, GroupByMonth:
+	default:
+		return nil, ErrInvalidGroupBy
+	}
+	err := query.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	options := SpentOptions
+	options.Workers = goroutines
+	parts := make([]map[string][]types.Money, options.workers(len(s.payments)))
+	for i := range parts {
+		parts[i] = map[string][]types.Money{}
+	}
+	categories := s.categoryIndex()
+	refunds := options.refundTotals(s.payments)
+	tracker := s.track("analytics", len(s.payments))
+	defer tracker.done()
+
+	err = scanPayments(context.Background(), s.payments, options, tracker, func(part int, payment *types.Payment) {
+		//полностью возвращённый платёж расходом не считается
+		amount := options.amount(payment, refunds)
+		if amount > 0 && query.Match(*payment) {
+			key := groupKey(payment, groupBy, categories)
+			parts[part][key] = append(parts[part][key], amount)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string][]types.Money{}
+	for _, part := range parts {
+		for key, amounts := range part {
+			groups[key] = append(groups[key], amounts...)
+		}
+	}
+
+	stats := make([]SpendingStat, 0, len(groups))
+	for key, amounts := range groups {
+		sort.Slice(amounts, func(i, j int) bool { return amounts[i] < amounts[j] })
+		stat := SpendingStat{Key: key, Count: len(amounts)}
+		for _, amount := range amounts {
+			stat.Total += amount
+		}
+		stat.Average = stat.Total / types.Money(stat.Count)
+		stat.P50 = percentile(amounts, 50)
+		stat.P90 = percentile(amounts, 90)
+		stat.P99 = percentile(amounts, 99)
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Key < stats[j].Key })
+	return stats, nil
+}
@@ -0,0 +1,63 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestService_SpendingAnalytics_byCategory(t *testing.T) {
+	svc, _ := newTestQueryService(t)
+
+	page, err := svc.QueryPayments(PaymentQuery{MinAmount: 70_00}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Reject(page.Payments[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := svc.SpendingAnalytics(PaymentQuery{}, GroupByCategory, 3)
+	if err != nil {
+		t.Fatalf("SpendingAnalytics(): error = %v", err)
+	}
+	want := []SpendingStat{
+		{Key: "auto", Total: 100_00, Count: 3, Average: 33_33, P50: 40_00, P90: 50_00, P99: 50_00},
+		{Key: "food", Total: 80_00, Count: 2, Average: 40_00, P50: 20_00, P90: 60_00, P99: 60_00},
+		{Key: "restaurant", Total: 30_00, Count: 1, Average: 30_00, P50: 30_00, P90: 30_00, P99: 30_00},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("SpendingAnalytics(): want => %v got => %v", want, got)
+	}
+}
+
+func TestService_SpendingAnalytics_byPeriod(t *testing.T) {
+	svc, clock := newTestQueryService(t)
+	clock.Add(24 * time.Hour)
+	_, err := svc.Pay(1, 5_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := svc.SpendingAnalytics(PaymentQuery{AccountIDs: []int64{1}}, GroupByDay, 2)
+	if err != nil {
+		t.Fatalf("SpendingAnalytics(): error = %v", err)
+	}
+	if len(got) != 2 || got[0].Key != "2020-11-20" || got[0].Total != 210_00 || got[1].Key != "2020-11-21" || got[1].Total != 5_00 {
+		t.Errorf("SpendingAnalytics(): wrong result = %v", got)
+	}
+
+	month, err := svc.SpendingAnalytics(PaymentQuery{}, GroupByMonth, 2)
+	if err != nil {
+		t.Fatalf("SpendingAnalytics(): error = %v", err)
+	}
+	if len(month) != 1 || month[0].Key != "2020-11" || month[0].Count != 8 {
+		t.Errorf("SpendingAnalytics(): wrong result = %v", month)
+	}
+
+	_, err = svc.SpendingAnalytics(PaymentQuery{}, "year", 2)
+	if err != ErrInvalidGroupBy {
+		t.Errorf("SpendingAnalytics(): must return ErrInvalidGroupBy, returned = %v", err)
+	}
+}
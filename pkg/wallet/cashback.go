@@ -0,0 +1,260 @@
+package wallet
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrPaymentNotInProgress = errors.New("payment is not in progress")
+
+//SetCashbackRule - задаёт кешбэк для категории, заменяя предыдущее правило.
+//Категория приводится к коду из справочника.
+func (s *Service) SetCashbackRule(rule types.CashbackRule) error {
+	if rule.Percent < 0 || rule.MonthlyCap < 0 {
+		return ErrAmountMustBePositive
+	}
+	index := s.categoryIndex()
+	rule.Category = canonicalCategory(index, rule.Category)
+	for i, saved := range s.cashback {
+		if sameCategory(index, saved.Category, rule.Category) {
+			s.cashback[i] = &rule
+			return nil
+		}
+	}
+	s.cashback = append(s.cashback, &rule)
+	return nil
+}
+
+//Complete - подтверждает проведение платежа: INPROGRESS -> OK. Комиссия за платёж
+//тоже проводится, а по правилам кешбэка на счёт начисляется вознаграждение.
+func (s *Service) Complete(paymentID string) (*types.Payment, error) {
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != types.PaymentStatusInProgress {
+		return nil, ErrPaymentNotInProgress
+	}
+
+	now := s.now()
+	payment.Status = types.PaymentStatusOk
+	payment.UpdatedAt = now
+	s.recordPayment(OperationComplete, payment)
+	fee, err := s.FindFee(payment.ID)
+	if err == nil && fee.Status == types.PaymentStatusInProgress {
+		fee.Status = types.PaymentStatusOk
+		fee.UpdatedAt = now
+		s.recordPayment(OperationComplete, fee)
+	}
+
+	s.accrueCashback(payment)
+	return payment, nil
+}
+
+//FindCashback - действующий кешбэк, начисленный за платёж
+func (s *Service) FindCashback(paymentID string) (*types.Payment, error) {
+	for _, payment := range s.payments {
+		if payment.Status == types.PaymentStatusCashback && payment.ParentID == paymentID {
+			return payment, nil
+		}
+	}
+	return nil, ErrPaymentNotFound
+}
+
+//accrueCashback - начисляет кешбэк за проведённый платёж с учётом месячного лимита категории.
+//Переводы между счетами - не покупки, кешбэк за них не начисляется.
+func (s *Service) accrueCashback(payment *types.Payment) {
+	if payment.ToAccountID != 0 {
+		return
+	}
+	index := s.categoryIndex()
+	var rule *types.CashbackRule
+	for _, saved := range s.cashback {
+		if sameCategory(index, saved.Category, payment.Category) {
+			rule = saved
+		}
+	}
+	if rule == nil {
+		return
+	}
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil || account.Status == types.AccountStatusClosed {
+		return
+	}
+
+	now := s.now()
+	amount := types.Money(int64(payment.Amount) * rule.Percent / 10_000)
+	if rule.MonthlyCap != 0 {
+		accrued := types.Money(0)
+		from := monthStart(now)
+		for _, saved := range s.payments {
+			if saved.AccountID == account.ID && saved.Status == types.PaymentStatusCashback &&
+				sameCategory(index, saved.Category, rule.Category) && !saved.CreatedAt.Before(from) {
+				accrued += saved.Amount
+			}
+		}
+		if accrued+amount > rule.MonthlyCap {
+			amount = rule.MonthlyCap - accrued
+		}
+	}
+	if amount <= 0 {
+		return
+	}
+
+	account.Balance += amount
+	account.UpdatedAt = now
+	s.updateOverdraft(account)
+	accrual := &types.Payment{
+		ID:        uuid.New().String(),
+		AccountID: account.ID,
+		Amount:    amount,
+		Category:  payment.Category,
+		Status:    types.PaymentStatusCashback,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ParentID:  payment.ID,
+	}
+	s.payments = append(s.payments, accrual)
+	s.recordAccount(OperationCashback, account)
+	s.recordPayment(OperationCashback, accrual)
+}
+
+//clawbackCashback - при отмене платежа отменяет начисленный за него кешбэк.
+//Возвращает сумму, которую нужно списать со счёта.
+func (s *Service) clawbackCashback(payment *types.Payment) types.Money {
+	accrual, err := s.FindCashback(payment.ID)
+	if err != nil {
+		return 0
+	}
+	accrual.Status = types.PaymentStatusClawback
+	accrual.UpdatedAt = payment.UpdatedAt
+	s.recordPayment(OperationClawback, accrual)
+	return accrual.Amount
+}
+
+//clawbackRefund - при возврате части платежа отменяет такую же часть начисленного за него кешбэка,
+//refunded - сколько возвращено по платежу всего. Отменённая часть отделяется от начисления
+//записью CLAWBACK, при полном возврате отменяется всё начисление, как при Reject.
+//Возвращает сумму, которую нужно списать со счёта.
+func (s *Service) clawbackRefund(payment *types.Payment, refunded types.Money) types.Money {
+	accrual, err := s.FindCashback(payment.ID)
+	if err != nil {
+		return 0
+	}
+	clawedBack := types.Money(0)
+	for _, saved := range s.payments {
+		if saved.Status == types.PaymentStatusClawback && saved.ParentID == payment.ID {
+			clawedBack += saved.Amount
+		}
+	}
+	total := accrual.Amount + clawedBack
+	amount := types.Money(int64(total)*int64(refunded)/int64(payment.Amount)) - clawedBack
+	if amount <= 0 {
+		return 0
+	}
+
+	now := s.now()
+	accrual.UpdatedAt = now
+	if amount >= accrual.Amount {
+		accrual.Status = types.PaymentStatusClawback
+		s.recordPayment(OperationClawback, accrual)
+		return accrual.Amount
+	}
+	accrual.Amount -= amount
+	clawback := &types.Payment{
+		ID:        uuid.New().String(),
+		AccountID: accrual.AccountID,
+		Amount:    amount,
+		Category:  accrual.Category,
+		Status:    types.PaymentStatusClawback,
+		CreatedAt: accrual.CreatedAt,
+		UpdatedAt: now,
+		ParentID:  payment.ID,
+	}
+	s.payments = append(s.payments, clawback)
+	s.recordPayment(OperationClawback, accrual)
+	s.recordPayment(OperationClawback, clawback)
+	return amount
+}
+
+//RewardBalance - кешбэк аккаунта
+type RewardBalance struct {
+	AccountID  int64
+	Accrued    types.Money //Начислено за всё время
+	ClawedBack types.Money //Отменено вместе с платежами
+	Balance    types.Money //Accrued - ClawedBack
+	ThisMonth  types.Money //Действующий кешбэк за текущий месяц
+}
+
+//RewardReport - кешбэк по аккаунтам, у которых он когда-либо начислялся, по возрастанию ID
+func (s *Service) RewardReport() []RewardBalance {
+	from := monthStart(s.now())
+	balances := map[int64]*RewardBalance{}
+	for _, payment := range s.payments {
+		if payment.Status != types.PaymentStatusCashback && payment.Status != types.PaymentStatusClawback {
+			continue
+		}
+		balance, ok := balances[payment.AccountID]
+		if !ok {
+			balance = &RewardBalance{AccountID: payment.AccountID}
+			balances[payment.AccountID] = balance
+		}
+		balance.Accrued += payment.Amount
+		if payment.Status != types.PaymentStatusCashback {
+			balance.ClawedBack += payment.Amount
+			continue
+		}
+		balance.Balance += payment.Amount
+		if !payment.CreatedAt.Before(from) {
+			balance.ThisMonth += payment.Amount
+		}
+	}
+
+	report := make([]RewardBalance, 0, len(balances))
+	for _, balance := range balances {
+		report = append(report, *balance)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].AccountID < report[j].AccountID })
+	return report
+}
+
+//exportCashback - сохраняет правила кешбэка в dir/cashback.dump: категория;процент;лимит
+func (s *Service) exportCashback(dir string) error {
+	records := make([]string, 0, len(s.cashback))
+	for _, rule := range s.cashback {
+		records = append(records, string(rule.Category)+";"+strconv.FormatInt(rule.Percent, 10)+";"+
+			strconv.FormatInt(int64(rule.MonthlyCap), 10))
+	}
+	return writeDump(dir, "cashback.dump", records)
+}
+
+//importCashback - загружает правила кешбэка из dir/cashback.dump
+func (s *Service) importCashback(dir string) error {
+	values, err := readDump(dir, "cashback.dump")
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		if len(value) < 3 {
+			return ErrInvalidDump
+		}
+		percent, err := strconv.ParseInt(value[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		monthlyCap, err := strconv.ParseInt(value[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		s.cashback = append(s.cashback, &types.CashbackRule{
+			Category:   types.PaymentCategory(value[0]),
+			Percent:    percent,
+			MonthlyCap: types.Money(monthlyCap),
+		})
+	}
+	return nil
+}
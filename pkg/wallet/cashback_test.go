@@ -0,0 +1,189 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func TestService_Complete_accruesCashback(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 10_000_00)
+	svc.SetCashbackRule(types.CashbackRule{Category: "restaurant", Percent: 500, MonthlyCap: 30_00})
+
+	payment, err := svc.Pay(account.ID, 200_00, "restaurant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = svc.FindCashback(payment.ID); err != ErrPaymentNotFound {
+		t.Errorf("Pay(): cashback must wait for OK, returned = %v", err)
+	}
+
+	_, err = svc.Complete(payment.ID)
+	if err != nil {
+		t.Fatalf("Complete(): error = %v", err)
+	}
+	if payment.Status != types.PaymentStatusOk {
+		t.Errorf("Complete(): wrong status = %v", payment.Status)
+	}
+	cashback, err := svc.FindCashback(payment.ID)
+	if err != nil {
+		t.Fatalf("FindCashback(): error = %v", err)
+	}
+	if cashback.Amount != 10_00 || account.Balance != 9_810_00 {
+		t.Errorf("Complete(): wrong cashback = %v, balance = %v", cashback, account.Balance)
+	}
+	_, err = svc.Complete(payment.ID)
+	if err != ErrPaymentNotInProgress {
+		t.Errorf("Complete(): must return ErrPaymentNotInProgress, returned = %v", err)
+	}
+
+	history, err := svc.ExportAccountHistory(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[1].ID != cashback.ID {
+		t.Errorf("ExportAccountHistory(): cashback must follow payment, history = %v", history)
+	}
+
+	//другие категории без кешбэка
+	other, err := svc.Pay(account.ID, 200_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Complete(other.ID)
+	if _, err = svc.FindCashback(other.ID); err != ErrPaymentNotFound {
+		t.Errorf("Complete(): no cashback expected for auto, returned = %v", err)
+	}
+}
+
+func TestService_Cashback_monthlyCap(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 10_000_00)
+	svc.SetCashbackRule(types.CashbackRule{Category: "restaurant", Percent: 500, MonthlyCap: 30_00})
+
+	amounts := []types.Money{}
+	for i := 0; i < 3; i++ {
+		payment, err := svc.Pay(account.ID, 250_00, "restaurant")
+		if err != nil {
+			t.Fatal(err)
+		}
+		svc.Complete(payment.ID)
+		cashback, err := svc.FindCashback(payment.ID)
+		if err != nil {
+			amounts = append(amounts, 0)
+			continue
+		}
+		amounts = append(amounts, cashback.Amount)
+	}
+	want := []types.Money{12_50, 12_50, 5_00}
+	if !reflect.DeepEqual(want, amounts) {
+		t.Errorf("Complete(): want => %v got => %v", want, amounts)
+	}
+
+	clock.Add(11 * 24 * time.Hour)
+	payment, err := svc.Pay(account.ID, 100_00, "restaurant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Complete(payment.ID)
+	if cashback, err := svc.FindCashback(payment.ID); err != nil || cashback.Amount != 5_00 {
+		t.Errorf("Complete(): next month cashback = %v, error = %v", cashback, err)
+	}
+}
+
+func TestService_Reject_clawsBackCashback(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 10_000_00)
+	svc.SetCashbackRule(types.CashbackRule{Category: "restaurant", Percent: 500, MonthlyCap: 30_00})
+
+	payment, err := svc.Pay(account.ID, 200_00, "restaurant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Complete(payment.ID)
+	cashback, err := svc.FindCashback(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Reject(cashback.ID)
+	if err != ErrPaymentNotRefundable {
+		t.Errorf("Reject(): cashback itself can't be rejected, returned = %v", err)
+	}
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 10_000_00 || cashback.Status != types.PaymentStatusClawback {
+		t.Errorf("Reject(): cashback must be clawed back, balance = %v, cashback = %v", account.Balance, cashback)
+	}
+
+	payment, err = svc.Pay(account.ID, 100_00, "restaurant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Complete(payment.ID)
+
+	want := []RewardBalance{{AccountID: account.ID, Accrued: 15_00, ClawedBack: 10_00, Balance: 5_00, ThisMonth: 5_00}}
+	if got := svc.RewardReport(); !reflect.DeepEqual(want, got) {
+		t.Errorf("RewardReport(): want => %v got => %v", want, got)
+	}
+}
+
+func TestService_Refund_clawsBackCashback(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 100_00)
+	svc.SetCashbackRule(types.CashbackRule{Category: "restaurant", Percent: 1000})
+
+	payment, err := svc.Pay(account.ID, 50_00, "restaurant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Complete(payment.ID)
+	cashback, err := svc.FindCashback(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Refund(payment.ID, 20_00, "")
+	if err != nil {
+		t.Fatalf("Refund(): error = %v", err)
+	}
+	if account.Balance != 73_00 || cashback.Amount != 3_00 || cashback.Status != types.PaymentStatusCashback {
+		t.Errorf("Refund(): partial clawback, balance = %v, cashback = %v", account.Balance, cashback)
+	}
+	want := []RewardBalance{{AccountID: account.ID, Accrued: 5_00, ClawedBack: 2_00, Balance: 3_00, ThisMonth: 3_00}}
+	if got := svc.RewardReport(); !reflect.DeepEqual(want, got) {
+		t.Errorf("RewardReport(): want => %v got => %v", want, got)
+	}
+
+	_, err = svc.Refund(payment.ID, 30_00, "")
+	if err != nil {
+		t.Fatalf("Refund(): error = %v", err)
+	}
+	if account.Balance != 100_00 || cashback.Status != types.PaymentStatusClawback {
+		t.Errorf("Refund(): full refund must claw back all cashback, balance = %v, cashback = %v", account.Balance, cashback)
+	}
+	want = []RewardBalance{{AccountID: account.ID, Accrued: 5_00, ClawedBack: 5_00}}
+	if got := svc.RewardReport(); !reflect.DeepEqual(want, got) {
+		t.Errorf("RewardReport(): want => %v got => %v", want, got)
+	}
+}
+
+func TestService_Cashback_exportImport(t *testing.T) {
+	svc, _, _ := newTestServiceUserWithClock(t, 10_000_00)
+	svc.SetCashbackRule(types.CashbackRule{Category: "restaurant", Percent: 500, MonthlyCap: 30_00})
+
+	dir := t.TempDir()
+	err := svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported.cashback) != 1 || *imported.cashback[0] != *svc.cashback[0] {
+		t.Errorf("Import(): wrong cashback rules = %v", imported.cashback)
+	}
+}
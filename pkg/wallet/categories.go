@@ -0,0 +1,254 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+var ErrUnknownCategory = errors.New("unknown category")
+var ErrCategoryNotFound = errors.New("category not found")
+var ErrInvalidCategory = errors.New("category code must not be empty or contain ';', '|' or ','")
+var ErrCategoryParent = errors.New("parent category not found or makes a cycle")
+var ErrCategoryAlias = errors.New("alias is already used by another category")
+
+//CategoryMode представляет собой режим проверки категорий платежей по справочнику
+type CategoryMode int
+
+//Режимы проверки категорий
+const (
+	//CategoryLenient - известные категории и их синонимы приводятся к коду, неизвестные принимаются как есть
+	CategoryLenient CategoryMode = iota
+	//CategoryStrict - платежи с категориями не из справочника отклоняются
+	CategoryStrict
+)
+
+//SetCategoryMode - задаёт режим проверки категорий в Pay и Authorize
+func (s *Service) SetCategoryMode(mode CategoryMode) {
+	s.categoryMode = mode
+}
+
+//normalizeCategory - категории сравниваются без учёта регистра и пробелов по краям
+func normalizeCategory(category types.PaymentCategory) types.PaymentCategory {
+	return types.PaymentCategory(strings.ToLower(strings.TrimSpace(string(category))))
+}
+
+//validCategoryCode - код не ломает формат дампа справочника
+func validCategoryCode(code types.PaymentCategory) bool {
+	return code != "" && !strings.ContainsAny(string(code), ";|,")
+}
+
+//RegisterCategory - добавляет категорию в справочник или заменяет категорию с тем же кодом.
+//Родитель должен быть уже зарегистрирован.
+func (s *Service) RegisterCategory(category types.Category) error {
+	category.Code = normalizeCategory(category.Code)
+	category.Parent = normalizeCategory(category.Parent)
+	if !validCategoryCode(category.Code) || strings.ContainsAny(category.Name, ";|") {
+		return ErrInvalidCategory
+	}
+	aliases := make([]types.PaymentCategory, 0, len(category.Aliases))
+	for _, alias := range category.Aliases {
+		alias = normalizeCategory(alias)
+		if !validCategoryCode(alias) {
+			return ErrInvalidCategory
+		}
+		found, err := s.FindCategory(alias)
+		if err == nil && found.Code != category.Code {
+			return ErrCategoryAlias
+		}
+		aliases = append(aliases, alias)
+	}
+	category.Aliases = aliases
+	found, err := s.FindCategory(category.Code)
+	if err == nil && found.Code != category.Code {
+		return ErrCategoryAlias
+	}
+
+	//родитель существует и среди его предков нет самой категории
+	for parent := category.Parent; parent != ""; {
+		if parent == category.Code {
+			return ErrCategoryParent
+		}
+		found, err := s.FindCategory(parent)
+		if err != nil || found.Code != parent {
+			return ErrCategoryParent
+		}
+		parent = found.Parent
+	}
+
+	for i, saved := range s.categories {
+		if saved.Code == category.Code {
+			s.categories[i] = &category
+			return nil
+		}
+	}
+	s.categories = append(s.categories, &category)
+	return nil
+}
+
+//FindCategory - находит категорию по коду или синониму
+func (s *Service) FindCategory(category types.PaymentCategory) (*types.Category, error) {
+	category = normalizeCategory(category)
+	for _, saved := range s.categories {
+		if saved.Code == category {
+			return saved, nil
+		}
+	}
+	for _, saved := range s.categories {
+		for _, alias := range saved.Aliases {
+			if alias == category {
+				return saved, nil
+			}
+		}
+	}
+	return nil, ErrCategoryNotFound
+}
+
+//ListCategories - справочник категорий в порядке регистрации
+func (s *Service) ListCategories() []types.Category {
+	categories := make([]types.Category, 0, len(s.categories))
+	for _, category := range s.categories {
+		categories = append(categories, *category)
+	}
+	return categories
+}
+
+//resolveCategory - приводит категорию платежа к коду из справочника.
+//Если справочник пуст, категории не проверяются вовсе.
+func (s *Service) resolveCategory(category types.PaymentCategory) (types.PaymentCategory, error) {
+	if len(s.categories) == 0 {
+		return category, nil
+	}
+	found, err := s.FindCategory(category)
+	if err == nil {
+		return found.Code, nil
+	}
+	if s.categoryMode == CategoryStrict {
+		return "", ErrUnknownCategory
+	}
+	return category, nil
+}
+
+//categoryIndex - категории по коду и синонимам для быстрого поиска при сканировании платежей
+func (s *Service) categoryIndex() map[types.PaymentCategory]*types.Category {
+	index := map[types.PaymentCategory]*types.Category{}
+	for _, category := range s.categories {
+		for _, alias := range category.Aliases {
+			index[alias] = category
+		}
+	}
+	for _, category := range s.categories {
+		index[category.Code] = category
+	}
+	return index
+}
+
+//sameCategory - категории совпадают с учётом синонимов из справочника. Платежи, лимиты и правила
+//кешбэка могли быть сохранены под синонимом или до регистрации категории, поэтому сравниваются коды.
+func sameCategory(index map[types.PaymentCategory]*types.Category, a types.PaymentCategory, b types.PaymentCategory) bool {
+	return canonicalCategory(index, a) == canonicalCategory(index, b)
+}
+
+//canonicalCategory - код категории из справочника, неизвестная категория - как есть
+func canonicalCategory(index map[types.PaymentCategory]*types.Category, category types.PaymentCategory) types.PaymentCategory {
+	found, ok := index[normalizeCategory(category)]
+	if !ok {
+		return category
+	}
+	return found.Code
+}
+
+//rootCategory - категория верхнего уровня, к которой относится category
+func rootCategory(index map[types.PaymentCategory]*types.Category, category types.PaymentCategory) types.PaymentCategory {
+	found, ok := index[normalizeCategory(category)]
+	if !ok {
+		return category
+	}
+	for found.Parent != "" {
+		parent, ok := index[found.Parent]
+		if !ok {
+			return found.Parent
+		}
+		found = parent
+	}
+	return found.Code
+}
+
+//exportCategories - сохраняет справочник в dir/categories.dump: код;название;родитель;синоним,синоним.
+//Родитель записывается раньше дочерних категорий, чтобы importCategories мог зарегистрировать их по порядку,
+//даже если категорию перенесли под родителя, добавленного позже неё. Режим проверки - в dir/categorymode.dump.
+func (s *Service) exportCategories(dir string) error {
+	index := s.categoryIndex()
+	written := map[types.PaymentCategory]bool{}
+	records := make([]string, 0, len(s.categories))
+	var write func(category *types.Category)
+	write = func(category *types.Category) {
+		if written[category.Code] {
+			return
+		}
+		written[category.Code] = true
+		parent, ok := index[category.Parent]
+		if ok {
+			write(parent)
+		}
+		aliases := make([]string, 0, len(category.Aliases))
+		for _, alias := range category.Aliases {
+			aliases = append(aliases, string(alias))
+		}
+		records = append(records, string(category.Code)+";"+category.Name+";"+string(category.Parent)+";"+
+			strings.Join(aliases, ","))
+	}
+	for _, category := range s.categories {
+		write(category)
+	}
+	err := writeDump(dir, "categories.dump", records)
+	if err != nil {
+		return err
+	}
+
+	mode := []string{}
+	if s.categoryMode == CategoryStrict {
+		mode = append(mode, "strict")
+	}
+	return writeDump(dir, "categorymode.dump", mode)
+}
+
+//importCategories - загружает справочник из dir/categories.dump и режим проверки из dir/categorymode.dump
+func (s *Service) importCategories(dir string) error {
+	values, err := readDump(dir, "categories.dump")
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		if len(value) < 4 {
+			return ErrInvalidDump
+		}
+		category := types.Category{
+			Code:   types.PaymentCategory(value[0]),
+			Name:   value[1],
+			Parent: types.PaymentCategory(value[2]),
+		}
+		if value[3] != "" {
+			for _, alias := range strings.Split(value[3], ",") {
+				category.Aliases = append(category.Aliases, types.PaymentCategory(alias))
+			}
+		}
+		err = s.RegisterCategory(category)
+		if err != nil {
+			return err
+		}
+	}
+
+	values, err = readDump(dir, "categorymode.dump")
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		if value[0] != "strict" {
+			return ErrInvalidDump
+		}
+		s.categoryMode = CategoryStrict
+	}
+	return nil
+}
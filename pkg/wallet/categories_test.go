@@ -0,0 +1,273 @@
+package wallet
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+//testCategories - справочник для тестов: покупки > продукты > рестораны и авто
+var testCategories = []types.Category{
+	{Code: "shopping", Name: "Покупки"},
+	{Code: "food", Name: "Продукты", Parent: "shopping", Aliases: []types.PaymentCategory{"groceries"}},
+	{Code: "restaurant", Name: "Рестораны", Parent: "food", Aliases: []types.PaymentCategory{"cafe", "Bar"}},
+	{Code: "auto", Name: "Авто"},
+}
+
+func TestService_RegisterCategory_validation(t *testing.T) {
+	svc, _, _ := newTestServiceUserWithClock(t, 1_000_00)
+	for _, category := range testCategories {
+		svc.RegisterCategory(category)
+	}
+
+	tests := []struct {
+		category types.Category
+		want     error
+	}{
+		{types.Category{Code: " "}, ErrInvalidCategory},
+		{types.Category{Code: "a;b"}, ErrInvalidCategory},
+		{types.Category{Code: "taxi", Parent: "transport"}, ErrCategoryParent},
+		{types.Category{Code: "taxi", Aliases: []types.PaymentCategory{"CAFE"}}, ErrCategoryAlias},
+		{types.Category{Code: "groceries"}, ErrCategoryAlias},
+		{types.Category{Code: "shopping", Parent: "restaurant"}, ErrCategoryParent},
+	}
+	for _, test := range tests {
+		err := svc.RegisterCategory(test.category)
+		if err != test.want {
+			t.Errorf("RegisterCategory(%v): want => %v got => %v", test.category, test.want, err)
+		}
+	}
+
+	found, err := svc.FindCategory(" bar ")
+	if err != nil || found.Code != "restaurant" {
+		t.Errorf("FindCategory(): wrong category = %v, error = %v", found, err)
+	}
+}
+
+func TestService_Pay_categoryMode(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	for _, category := range testCategories {
+		svc.RegisterCategory(category)
+	}
+
+	payment, err := svc.Pay(account.ID, 10_00, "Cafe")
+	if err != nil {
+		t.Fatalf("Pay(): error = %v", err)
+	}
+	if payment.Category != "restaurant" {
+		t.Errorf("Pay(): alias must be resolved, category = %v", payment.Category)
+	}
+	payment, err = svc.Pay(account.ID, 10_00, "games")
+	if err != nil || payment.Category != "games" {
+		t.Errorf("Pay(): lenient mode must accept unknown category, payment = %v, error = %v", payment, err)
+	}
+
+	svc.SetCategoryMode(CategoryStrict)
+	_, err = svc.Pay(account.ID, 10_00, "games")
+	if err != ErrUnknownCategory {
+		t.Errorf("Pay(): must return ErrUnknownCategory, returned = %v", err)
+	}
+	_, err = svc.Authorize(account.ID, 10_00, "games")
+	if err != ErrUnknownCategory {
+		t.Errorf("Authorize(): must return ErrUnknownCategory, returned = %v", err)
+	}
+	if account.Balance != 980_00 {
+		t.Errorf("Pay(): rejected payments must not change balance = %v", account.Balance)
+	}
+}
+
+func TestService_SpendingAnalytics_byParent(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	for _, category := range testCategories {
+		svc.RegisterCategory(category)
+	}
+	for _, payment := range []struct {
+		amount   types.Money
+		category types.PaymentCategory
+	}{
+		{10_00, "groceries"},
+		{20_00, "cafe"},
+		{30_00, "auto"},
+		{40_00, "games"},
+	} {
+		_, err := svc.Pay(account.ID, payment.amount, payment.category)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := svc.SpendingAnalytics(PaymentQuery{}, GroupByParent, 2)
+	if err != nil {
+		t.Fatalf("SpendingAnalytics(): error = %v", err)
+	}
+	want := []SpendingStat{
+		{Key: "auto", Total: 30_00, Count: 1, Average: 30_00, P50: 30_00, P90: 30_00, P99: 30_00},
+		{Key: "games", Total: 40_00, Count: 1, Average: 40_00, P50: 40_00, P90: 40_00, P99: 40_00},
+		{Key: "shopping", Total: 30_00, Count: 2, Average: 15_00, P50: 10_00, P90: 20_00, P99: 20_00},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("SpendingAnalytics(): want => %v got => %v", want, got)
+	}
+}
+
+func TestService_Categories_exportImport(t *testing.T) {
+	svc, _, _ := newTestServiceUserWithClock(t, 1_000_00)
+	for _, category := range testCategories {
+		svc.RegisterCategory(category)
+	}
+	svc.SetCategoryMode(CategoryStrict)
+	//родитель зарегистрирован позже категории, которую перенесли под него
+	err := svc.RegisterCategory(types.Category{Code: "transport", Name: "Транспорт"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.RegisterCategory(types.Category{Code: "auto", Name: "Авто", Parent: "transport"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatalf("Import(): error = %v", err)
+	}
+
+	want := svc.ListCategories()
+	if len(imported.ListCategories()) != len(want) {
+		t.Errorf("Import(): want => %v got => %v", want, imported.ListCategories())
+	}
+	for _, category := range want {
+		got, err := imported.FindCategory(category.Code)
+		if err != nil || !reflect.DeepEqual(category, *got) {
+			t.Errorf("Import(): want => %v got => %v, error = %v", category, got, err)
+		}
+	}
+	if imported.categoryMode != CategoryStrict {
+		t.Errorf("Import(): category mode must be restored")
+	}
+
+	svc.SetCategoryMode(CategoryLenient)
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported = &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.categoryMode != CategoryLenient {
+		t.Errorf("Import(): stale category mode must not be restored")
+	}
+}
+
+func TestService_Categories_limitsAndCashbackAliases(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	for _, category := range testCategories {
+		svc.RegisterCategory(category)
+	}
+	err := svc.SetLimits(types.Limits{AccountID: account.ID, Categories: map[types.PaymentCategory]types.Money{
+		"Cafe": 30_00,
+		"bar":  25_00,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[types.PaymentCategory]types.Money{"restaurant": 25_00}
+	if got := svc.FindLimits(account.ID).Categories; !reflect.DeepEqual(want, got) {
+		t.Errorf("SetLimits(): want => %v got => %v", want, got)
+	}
+	err = svc.SetCashbackRule(types.CashbackRule{Category: "groceries", Percent: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.Pay(account.ID, 20_00, "restaurant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Pay(account.ID, 10_00, "cafe")
+	limitErr := &ErrLimitExceeded{}
+	if !errors.As(err, &limitErr) || limitErr.Kind != LimitCategory || limitErr.Spent != 20_00 {
+		t.Errorf("Pay(): alias must count against category limit, returned = %v", err)
+	}
+
+	food, err := svc.Pay(account.ID, 50_00, "food")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Complete(food.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cashback, err := svc.FindCashback(food.ID)
+	if err != nil || cashback.Amount != 5_00 {
+		t.Errorf("Complete(): cashback rule set by alias must apply, cashback = %v, error = %v", cashback, err)
+	}
+
+	//лимиты и правила из дампа загружаются раньше справочника и сохранены под синонимами
+	svc.limits[0].Categories = map[types.PaymentCategory]types.Money{"cafe": 25_00}
+	svc.cashback[0].Category = "groceries"
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	imported.SetClock(newTestClock().Now)
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = imported.Pay(account.ID, 10_00, "bar")
+	if !errors.As(err, &limitErr) || limitErr.Spent != payment.Amount {
+		t.Errorf("Pay(): imported alias limit must apply, returned = %v", err)
+	}
+	food, err = imported.Pay(account.ID, 10_00, "food")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported.Complete(food.ID)
+	_, err = imported.FindCashback(food.ID)
+	if err != nil {
+		t.Errorf("Complete(): imported alias cashback rule must apply, error = %v", err)
+	}
+}
+
+func TestService_Categories_feeAliases(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	for _, category := range testCategories {
+		svc.RegisterCategory(category)
+	}
+	for _, rule := range []types.FeeRule{
+		{Category: "Cafe", Percent: 100},
+		{Category: "bar", Percent: 200},
+	} {
+		err := svc.SetFeeRule(rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(svc.fees) != 1 || svc.fees[0].Category != "restaurant" {
+		t.Errorf("SetFeeRule(): alias rule must replace the category rule, fees = %v", svc.fees)
+	}
+	for _, category := range []types.PaymentCategory{"restaurant", "cafe", " BAR "} {
+		got, err := svc.Fee(account.ID, 100_00, category)
+		if err != nil || got != 2_00 {
+			t.Errorf("Fee(%v): want => %v got => %v, error = %v", category, 2_00, got, err)
+		}
+	}
+
+	//правило из дампа загружается раньше справочника и сохранено под синонимом
+	svc.fees[0].Category = "cafe"
+	if got, _ := svc.Fee(account.ID, 100_00, "restaurant"); got != 2_00 {
+		t.Errorf("Fee(): rule saved under alias must apply, got = %v", got)
+	}
+}
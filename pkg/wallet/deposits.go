@@ -0,0 +1,39 @@
+package wallet
+
+import "github.com/Shahlojon/wallet/pkg/types"
+
+//FindDeposits - пополнения аккаунта в порядке поступления
+func (s *Service) FindDeposits(accountID int64) []types.Deposit {
+	deposits := []types.Deposit{}
+	for _, deposit := range s.deposits {
+		if deposit.AccountID == accountID {
+			deposits = append(deposits, *deposit)
+		}
+	}
+	return deposits
+}
+
+//exportDeposits - сохраняет пополнения в dir/deposits.dump
+func (s *Service) exportDeposits(dir string) error {
+	records := make([]string, 0, len(s.deposits))
+	for _, deposit := range s.deposits {
+		records = append(records, encodeDeposit(deposit))
+	}
+	return writeDump(dir, "deposits.dump", records)
+}
+
+//importDeposits - загружает пополнения из dir/deposits.dump
+func (s *Service) importDeposits(dir string) error {
+	values, err := readDump(dir, "deposits.dump")
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		deposit, err := decodeDeposit(value)
+		if err != nil {
+			return err
+		}
+		s.deposits = append(s.deposits, deposit)
+	}
+	return nil
+}
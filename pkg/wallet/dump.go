@@ -0,0 +1,373 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+//writeDump - записывает записи в dir/name, каждая запись завершается "|".
+//Если записей нет, старый файл удаляется, чтобы Import не загрузил устаревшие данные.
+func writeDump(dir string, name string, records []string) error {
+	path := filepath.Join(dir, name)
+	if len(records) == 0 {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			log.Print(err)
+			return ErrFileNotFound
+		}
+		return nil
+	}
+
+	err := ioutil.WriteFile(path, []byte(strings.Join(records, "|")+"|"), 0666)
+	if err != nil {
+		log.Print(err)
+		return ErrFileNotFound
+	}
+	return nil
+}
+
+//readDump - читает записи из dir/name, разбитые по полям. Если файла нет - записей нет.
+func readDump(dir string, name string) ([][]string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrFileNotFound
+	}
+
+	records := strings.Split(string(content), "|")
+	records = records[:len(records)-1]
+
+	values := make([][]string, 0, len(records))
+	for _, record := range records {
+		values = append(values, strings.Split(record, ";"))
+	}
+	return values, nil
+}
+
+//encodeAccount - сериализует аккаунт в строку формата дампа (без разделителя записей)
+func encodeAccount(account *types.Account) string {
+	id := strconv.FormatInt(account.ID, 10) + ";"
+	phone := string(account.Phone) + ";"
+	balance := strconv.FormatInt(int64(account.Balance), 10)
+
+	overdraft := strconv.FormatInt(int64(account.Overdraft), 10) + ";" +
+		strconv.FormatInt(int64(account.OverdraftGrace), 10) + ";" +
+		encodeTime(account.OverdraftSince) + ";" +
+		strconv.FormatInt(int64(account.Hold), 10) + ";" +
+		string(account.Status) + ";" + account.StatusReason + ";" +
+		account.Email + ";" + account.ExternalID + ";" + encodePhoneChanges(account.PreviousPhones) + ";" +
+		account.Profile.Name + ";" + account.Profile.DocumentNumber + ";" + string(account.Profile.Tier) + ";" +
+		strconv.FormatInt(int64(account.Turnover), 10) + ";" + encodeTime(account.TurnoverMonth)
+
+	return id + phone + balance + ";" + encodeTimes(account.CreatedAt, account.UpdatedAt) + ";" + overdraft
+}
+
+//decodeAccount - восстанавливает аккаунт из полей строки дампа
+func decodeAccount(value []string) (*types.Account, error) {
+	if len(value) < 3 {
+		return nil, ErrInvalidDump
+	}
+
+	id, err := strconv.ParseInt(value[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := strconv.ParseInt(value[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &types.Account{
+		ID:      id,
+		Phone:   types.Phone(value[1]),
+		Balance: types.Money(balance),
+	}
+	account.CreatedAt, account.UpdatedAt, err = decodeTimes(value[3:])
+	if err != nil {
+		return nil, err
+	}
+	if len(value) < 8 {
+		return account, nil
+	}
+
+	overdraft, err := strconv.ParseInt(value[5], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	grace, err := strconv.ParseInt(value[6], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	account.Overdraft = types.Money(overdraft)
+	account.OverdraftGrace = time.Duration(grace)
+	account.OverdraftSince, err = decodeTime(value[7])
+	if err != nil {
+		return nil, err
+	}
+	if len(value) < 9 {
+		return account, nil
+	}
+
+	hold, err := strconv.ParseInt(value[8], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	account.Hold = types.Money(hold)
+	if len(value) < 11 {
+		return account, nil
+	}
+
+	account.Status = types.AccountStatus(value[9])
+	account.StatusReason = value[10]
+	if len(value) < 14 {
+		return account, nil
+	}
+
+	account.Email = value[11]
+	account.ExternalID = value[12]
+	account.PreviousPhones, err = decodePhoneChanges(value[13])
+	if err != nil {
+		return nil, err
+	}
+	if len(value) < 19 {
+		return account, nil
+	}
+
+	account.Profile = types.Profile{
+		Name:           value[14],
+		DocumentNumber: value[15],
+		Tier:           types.VerificationTier(value[16]),
+	}
+	turnover, err := strconv.ParseInt(value[17], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	account.Turnover = types.Money(turnover)
+	account.TurnoverMonth, err = decodeTime(value[18])
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+//encodePhoneChanges - сериализует прежние номера: номер,время,номер,время
+func encodePhoneChanges(changes []types.PhoneChange) string {
+	values := make([]string, 0, len(changes)*2)
+	for _, change := range changes {
+		values = append(values, string(change.Phone), encodeTime(change.ChangedAt))
+	}
+	return strings.Join(values, ",")
+}
+
+//decodePhoneChanges - восстанавливает прежние номера, сериализованные encodePhoneChanges
+func decodePhoneChanges(value string) ([]types.PhoneChange, error) {
+	if value == "" {
+		return nil, nil
+	}
+	values := strings.Split(value, ",")
+	if len(values)%2 != 0 {
+		return nil, ErrInvalidDump
+	}
+
+	changes := make([]types.PhoneChange, 0, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		changedAt, err := decodeTime(values[i+1])
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, types.PhoneChange{Phone: types.Phone(values[i]), ChangedAt: changedAt})
+	}
+	return changes, nil
+}
+
+//encodePayment - сериализует платёж в строку формата дампа (без разделителя записей)
+func encodePayment(payment *types.Payment) string {
+	id := payment.ID + ";"
+	accountID := strconv.FormatInt(payment.AccountID, 10) + ";"
+	amount := strconv.FormatInt(int64(payment.Amount), 10) + ";"
+	category := string(payment.Category) + ";"
+	status := string(payment.Status)
+
+	return id + accountID + amount + category + status + ";" + encodeTimes(payment.CreatedAt, payment.UpdatedAt) + ";" +
+		encodeTime(payment.ExpiresAt) + ";" + payment.ParentID + ";" + payment.Reason + ";" +
+		strconv.FormatInt(payment.ToAccountID, 10) + ";" + strconv.FormatInt(payment.MerchantID, 10)
+}
+
+//decodePayment - восстанавливает платёж из полей строки дампа
+func decodePayment(value []string) (*types.Payment, error) {
+	if len(value) < 5 {
+		return nil, ErrInvalidDump
+	}
+
+	accountID, err := strconv.ParseInt(value[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := strconv.ParseInt(value[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	payment := &types.Payment{
+		ID:        value[0],
+		AccountID: accountID,
+		Amount:    types.Money(amount),
+		Category:  types.PaymentCategory(value[3]),
+		Status:    types.PaymentStatus(value[4]),
+	}
+	payment.CreatedAt, payment.UpdatedAt, err = decodeTimes(value[5:])
+	if err != nil {
+		return nil, err
+	}
+	if len(value) < 8 {
+		return payment, nil
+	}
+
+	payment.ExpiresAt, err = decodeTime(value[7])
+	if err != nil {
+		return nil, err
+	}
+	if len(value) < 10 {
+		return payment, nil
+	}
+
+	payment.ParentID = value[8]
+	payment.Reason = value[9]
+	if len(value) < 11 {
+		return payment, nil
+	}
+
+	payment.ToAccountID, err = strconv.ParseInt(value[10], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) < 12 {
+		return payment, nil
+	}
+
+	payment.MerchantID, err = strconv.ParseInt(value[11], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+//encodeDeposit - сериализует пополнение: ID;аккаунт;сумма;время
+func encodeDeposit(deposit *types.Deposit) string {
+	return deposit.ID + ";" + strconv.FormatInt(deposit.AccountID, 10) + ";" +
+		strconv.FormatInt(int64(deposit.Amount), 10) + ";" + encodeTime(deposit.CreatedAt)
+}
+
+//decodeDeposit - восстанавливает пополнение из полей строки дампа
+func decodeDeposit(value []string) (*types.Deposit, error) {
+	if len(value) < 4 {
+		return nil, ErrInvalidDump
+	}
+	accountID, err := strconv.ParseInt(value[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := strconv.ParseInt(value[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	createdAt, err := decodeTime(value[3])
+	if err != nil {
+		return nil, err
+	}
+	return &types.Deposit{
+		ID:        value[0],
+		AccountID: accountID,
+		Amount:    types.Money(amount),
+		CreatedAt: createdAt,
+	}, nil
+}
+
+//encodeFavorite - сериализует избранное в строку формата дампа (без разделителя записей)
+func encodeFavorite(favorite *types.Favorite) string {
+	id := favorite.ID + ";"
+	accountID := strconv.FormatInt(favorite.AccountID, 10) + ";"
+	name := favorite.Name + ";"
+	amount := strconv.FormatInt(int64(favorite.Amount), 10) + ";"
+	category := string(favorite.Category)
+
+	return id + accountID + name + amount + category + ";" + encodeTimes(favorite.CreatedAt, favorite.UpdatedAt)
+}
+
+//decodeFavorite - восстанавливает избранное из полей строки дампа
+func decodeFavorite(value []string) (*types.Favorite, error) {
+	if len(value) < 5 {
+		return nil, ErrInvalidDump
+	}
+
+	accountID, err := strconv.ParseInt(value[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := strconv.ParseInt(value[3], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	favorite := &types.Favorite{
+		ID:        value[0],
+		AccountID: accountID,
+		Name:      value[2],
+		Amount:    types.Money(amount),
+		Category:  types.PaymentCategory(value[4]),
+	}
+	favorite.CreatedAt, favorite.UpdatedAt, err = decodeTimes(value[5:])
+	if err != nil {
+		return nil, err
+	}
+	return favorite, nil
+}
+
+//encodeTime - сериализует время в наносекундах Unix, нулевое время - как 0
+func encodeTime(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+//decodeTime - восстанавливает время, сериализованное encodeTime
+func decodeTime(value string) (time.Time, error) {
+	nanos, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if nanos == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(0, nanos), nil
+}
+
+//encodeTimes - сериализует время создания и изменения записи
+func encodeTimes(createdAt, updatedAt time.Time) string {
+	return encodeTime(createdAt) + ";" + encodeTime(updatedAt)
+}
+
+//decodeTimes - восстанавливает время создания и изменения записи.
+//В старых дампах этих полей нет - тогда возвращается нулевое время.
+func decodeTimes(value []string) (createdAt time.Time, updatedAt time.Time, err error) {
+	if len(value) < 2 {
+		return
+	}
+	createdAt, err = decodeTime(value[0])
+	if err != nil {
+		return
+	}
+	updatedAt, err = decodeTime(value[1])
+	return
+}
@@ -0,0 +1,235 @@
+package wallet
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+//WorkersAuto - количество горутин по числу доступных процессоров (GOMAXPROCS)
+const WorkersAuto = 0
+
+//cancelCheckEvery - как часто (в платежах) горутины проверяют отмену контекста
+const cancelCheckEvery = 1024
+
+//ScanOptions - настройки параллельного обхода платежей
+type ScanOptions struct {
+	Workers         int                   //Количество горутин, WorkersAuto (или отрицательное) - GOMAXPROCS
+	IncludeStatuses []types.PaymentStatus //Если не пусто - учитываются только эти статусы
+	ExcludeStatuses []types.PaymentStatus //Эти статусы не учитываются
+	ExcludeFees     bool                  //Не учитывать записи комиссий за платежи
+	NetOfRefunds    bool                  //Считать платежи за вычетом возвратов по ним
+}
+
+//SpentOptions - учитываются только проведённые и проводимые платежи, отменённые - нет.
+//Комиссии расходами не считаются, возвращённая часть платежа - тоже.
+var SpentOptions = ScanOptions{
+	IncludeStatuses: []types.PaymentStatus{types.PaymentStatusOk, types.PaymentStatusInProgress},
+	ExcludeFees:     true,
+	NetOfRefunds:    true,
+}
+
+//workers - фактическое количество горутин для n платежей
+func (o ScanOptions) workers(n int) int {
+	workers := o.Workers
+	if workers <= WorkersAuto {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+//accepts - проверяет, учитывается ли платёж с таким статусом
+func (o ScanOptions) accepts(payment *types.Payment) bool {
+	if o.ExcludeFees && isFee(payment) {
+		return false
+	}
+	for _, status := range o.ExcludeStatuses {
+		if payment.Status == status {
+			return false
+		}
+	}
+	if len(o.IncludeStatuses) == 0 {
+		return true
+	}
+	for _, status := range o.IncludeStatuses {
+		if payment.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+//refundTotals - суммы возвратов по ID исходных платежей. Считается до параллельного обхода,
+//чтобы горутины только читали результат.
+func (o ScanOptions) refundTotals(payments []*types.Payment) map[string]types.Money {
+	refunds := map[string]types.Money{}
+	if !o.NetOfRefunds {
+		return refunds
+	}
+	for _, payment := range payments {
+		if payment.Status == types.PaymentStatusRefund {
+			refunds[payment.ParentID] += payment.Amount
+		}
+	}
+	return refunds
+}
+
+//amount - учитываемая сумма платежа; refunds - результат refundTotals
+func (o ScanOptions) amount(payment *types.Payment, refunds map[string]types.Money) types.Money {
+	if o.NetOfRefunds {
+		return payment.Amount - refunds[payment.ID]
+	}
+	return payment.Amount
+}
+
+//scanPayments - делит платежи на непрерывные части по числу горутин и вызывает visit
+//для каждого учитываемого платежа. part - номер части, части идут в порядке платежей,
+//поэтому результаты, собранные по частям, можно склеить без потери порядка.
+//Прогресс (если tracker не nil) отмечается порциями по cancelCheckEvery платежей.
+//Возвращает ошибку контекста, если обход был прерван.
+func scanPayments(ctx context.Context, payments []*types.Payment, options ScanOptions, tracker *progressTracker, visit func(part int, payment *types.Payment)) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	workers := options.workers(len(payments))
+	count := len(payments) / workers
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		from := i * count
+		to := from + count
+		if i == workers-1 {
+			to = len(payments)
+		}
+		wg.Add(1)
+		go func(part int, payments []*types.Payment) {
+			defer wg.Done()
+			for j, payment := range payments {
+				if j%cancelCheckEvery == 0 {
+					if ctx.Err() != nil {
+						return
+					}
+					if j > 0 {
+						tracker.add(cancelCheckEvery)
+					}
+				}
+				if options.accepts(payment) {
+					visit(part, payment)
+				}
+			}
+			if len(payments) > 0 {
+				tracker.add((len(payments)-1)%cancelCheckEvery + 1)
+			}
+		}(i, payments[from:to])
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+//SumPaymentsContext - параллельно суммирует платежи, подходящие под options
+func (s *Service) SumPaymentsContext(ctx context.Context, options ScanOptions) (types.Money, error) {
+	tracker := s.track("sum", len(s.payments))
+	defer tracker.done()
+
+	refunds := options.refundTotals(s.payments)
+	sums := make([]types.Money, options.workers(len(s.payments)))
+	err := scanPayments(ctx, s.payments, options, tracker, func(part int, payment *types.Payment) {
+		sums[part] += options.amount(payment, refunds)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sum := types.Money(0)
+	for _, value := range sums {
+		sum += value
+	}
+	return sum, nil
+}
+
+//SumPaymentsWithProgressContext - делит неотменённые платежи на parts частей (0 - по числу
+//процессоров), суммирует их параллельно и отправляет Progress{Part, Result} по мере готовности
+//каждой части. Сразу возвращает количество частей, чтобы можно было показать прогресс.
+//При отмене ctx оставшиеся части не отправляются, а канал закрывается.
+func (s *Service) SumPaymentsWithProgressContext(ctx context.Context, parts int) (int, <-chan Progress) {
+	payments := s.payments
+	if parts <= 0 {
+		parts = runtime.GOMAXPROCS(0)
+	}
+	if parts > len(payments) {
+		parts = len(payments)
+	}
+
+	refunds := SpentOptions.refundTotals(payments)
+	ch := make(chan Progress, parts)
+	count := 0
+	if parts > 0 {
+		count = len(payments) / parts
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < parts; i++ {
+		from := i * count
+		to := from + count
+		if i == parts-1 {
+			to = len(payments)
+		}
+		wg.Add(1)
+		go func(part int, payments []*types.Payment) {
+			defer wg.Done()
+			progress := Progress{Part: part}
+			for j, payment := range payments {
+				if j%cancelCheckEvery == 0 && ctx.Err() != nil {
+					return
+				}
+				if SpentOptions.accepts(payment) {
+					progress.Result += SpentOptions.amount(payment, refunds)
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			ch <- progress
+		}(i, payments[from:to])
+	}
+
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+	return parts, ch
+}
+
+//FilterPaymentsContext - параллельно отбирает платежи, для которых filter возвращает true.
+//Порядок платежей сохраняется. Если ничего не найдено - возвращается пустой результат без ошибки.
+func (s *Service) FilterPaymentsContext(ctx context.Context, filter func(payment types.Payment) bool, options ScanOptions) ([]types.Payment, error) {
+	tracker := s.track("filter", len(s.payments))
+	defer tracker.done()
+
+	parts := make([][]types.Payment, options.workers(len(s.payments)))
+	err := scanPayments(ctx, s.payments, options, tracker, func(part int, payment *types.Payment) {
+		if filter(*payment) {
+			parts[part] = append(parts[part], *payment)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	found := []types.Payment{}
+	for _, part := range parts {
+		found = append(found, part...)
+	}
+	return found, nil
+}
@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func TestService_SumPayments_excludesRejected(t *testing.T) {
+	svc, _ := newTestQueryService(t)
+
+	page, err := svc.QueryPayments(PaymentQuery{MinAmount: 70_00}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Reject(page.Payments[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, goroutines := range []int{-1, 0, 1, 3, 100} {
+		got := svc.SumPayments(goroutines)
+		if got != 210_00 {
+			t.Errorf("SumPayments(%v): want => %v got => %v", goroutines, 210_00, got)
+		}
+	}
+
+	failed, err := svc.SumPaymentsContext(context.Background(), ScanOptions{IncludeStatuses: []types.PaymentStatus{types.PaymentStatusFail}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 70_00 {
+		t.Errorf("SumPaymentsContext(): want => %v got => %v", 70_00, failed)
+	}
+}
+
+func TestService_SumPayments_empty(t *testing.T) {
+	svc := &Service{}
+	if got := svc.SumPayments(4); got != 0 {
+		t.Errorf("SumPayments(): want => 0 got => %v", got)
+	}
+}
+
+func TestService_FilterPayments_order(t *testing.T) {
+	svc, _ := newTestQueryService(t)
+
+	payments, err := svc.FilterPayments(1, 3)
+	if err != nil {
+		t.Fatalf("FilterPayments(): error = %v", err)
+	}
+	want := []types.Money{10_00, 30_00, 40_00, 60_00, 70_00}
+	if got := amountsOf(payments); !reflect.DeepEqual(want, got) {
+		t.Errorf("FilterPayments(): want => %v got => %v", want, got)
+	}
+}
+
+func TestService_FilterPayments_noMatches(t *testing.T) {
+	svc, _ := newTestQueryService(t)
+	account, err := svc.RegisterAccount("+992000000003")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payments, err := svc.FilterPayments(account.ID, 2)
+	if err != nil || len(payments) != 0 {
+		t.Errorf("FilterPayments(): must return empty result, returned = %v, %v", payments, err)
+	}
+
+	_, err = svc.FilterPayments(100, 2)
+	if err != ErrAccountNotFound {
+		t.Errorf("FilterPayments(): must return ErrAccountNotFound, returned = %v", err)
+	}
+
+	payments, err = svc.FilterPaymentsByFn(func(payment types.Payment) bool { return false }, 2)
+	if err != nil || len(payments) != 0 {
+		t.Errorf("FilterPaymentsByFn(): must return empty result, returned = %v, %v", payments, err)
+	}
+}
+
+func TestService_FilterPaymentsContext_canceled(t *testing.T) {
+	svc, _ := newTestQueryService(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := svc.FilterPaymentsContext(ctx, func(payment types.Payment) bool { return true }, ScanOptions{})
+	if err != context.Canceled {
+		t.Errorf("FilterPaymentsContext(): must return context.Canceled, returned = %v", err)
+	}
+}
+
+func TestService_SumPaymentsWithProgressContext_parts(t *testing.T) {
+	svc, _ := newTestQueryService(t)
+
+	parts, ch := svc.SumPaymentsWithProgressContext(context.Background(), 3)
+	if parts != 3 {
+		t.Errorf("SumPaymentsWithProgressContext(): want => 3 parts got => %v", parts)
+	}
+
+	seen := map[int]bool{}
+	total := types.Money(0)
+	for progress := range ch {
+		seen[progress.Part] = true
+		total += progress.Result
+	}
+	if len(seen) != parts || !seen[0] || !seen[1] || !seen[2] {
+		t.Errorf("SumPaymentsWithProgressContext(): wrong parts = %v", seen)
+	}
+	if total != svc.SumPayments(1) {
+		t.Errorf("SumPaymentsWithProgressContext(): want => %v got => %v", svc.SumPayments(1), total)
+	}
+}
+
+func TestService_SumPaymentsWithProgress_empty(t *testing.T) {
+	svc := &Service{}
+	for progress := range svc.SumPaymentsWithProgress() {
+		t.Errorf("SumPaymentsWithProgress(): unexpected progress = %v", progress)
+	}
+}
+
+func TestService_SumPaymentsWithProgressContext_canceled(t *testing.T) {
+	svc, _ := newTestQueryService(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, ch := svc.SumPaymentsWithProgressContext(ctx, 2)
+	for progress := range ch {
+		t.Errorf("SumPaymentsWithProgressContext(): unexpected progress = %v", progress)
+	}
+}
@@ -0,0 +1,341 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidSubscriber = errors.New("subscriber name must not be empty or contain ';', '|' or ','")
+var ErrSubscriberExists = errors.New("subscriber already exists")
+
+//EventType представляет собой тип события кошелька
+type EventType string
+
+//События, на которые можно подписаться
+const (
+	EventAccountRegistered EventType = "ACCOUNT_REGISTERED"
+	EventDeposited         EventType = "DEPOSITED"
+	EventPaymentCreated    EventType = "PAYMENT_CREATED"
+	EventPaymentRejected   EventType = "PAYMENT_REJECTED"
+	EventFavoriteCreated   EventType = "FAVORITE_CREATED"
+)
+
+//eventQueueSize - сколько событий может ждать асинхронного подписчика.
+//Не поместившиеся в очередь события остаются в outbox до DeliverEvents.
+const eventQueueSize = 64
+
+//Event - событие кошелька. AccountID, Phone и Balance (после операции) заполнены всегда,
+//из остальных полей - одно, в зависимости от типа: Account для AccountRegistered,
+//Deposit для Deposited, Payment для PaymentCreated и PaymentRejected, Favorite для FavoriteCreated.
+type Event struct {
+	ID        string
+	Type      EventType
+	Time      time.Time
+	AccountID int64
+	Phone     types.Phone
+	Balance   types.Money
+	Account   *types.Account
+	Deposit   *types.Deposit
+	Payment   *types.Payment
+	Favorite  *types.Favorite
+}
+
+//EventHandler - обработчик событий. Если он вернул ошибку, событие останется в outbox
+//и будет доставлено повторно, поэтому обработчик должен выдерживать повторы.
+type EventHandler func(event Event) error
+
+//subscriber - подписчик шины событий
+type subscriber struct {
+	name    string
+	handler EventHandler
+	types   map[EventType]bool //Пустой - все события
+	queue   chan *outboxEvent  //Для асинхронных подписчиков, nil - синхронный
+}
+
+//outboxEvent - событие, которое ещё не доставлено всем подписчикам
+type outboxEvent struct {
+	event   Event
+	pending map[string]bool //Имя подписчика -> доставка уже выполняется
+}
+
+//Subscribe - подписывает обработчик на события eventTypes (без типов - на все события).
+//Обработчик вызывается синхронно, сразу после успешной операции. Имя подписчика сохраняется
+//в outbox вместе с недоставленными событиями, поэтому после Import подписчик с тем же именем
+//получит их при DeliverEvents.
+func (s *Service) Subscribe(name string, handler EventHandler, eventTypes ...EventType) error {
+	return s.subscribe(name, handler, false, eventTypes)
+}
+
+//SubscribeAsync - как Subscribe, но обработчик вызывается в отдельной горутине по порядку событий.
+//Дождаться обработки можно через WaitEvents.
+func (s *Service) SubscribeAsync(name string, handler EventHandler, eventTypes ...EventType) error {
+	return s.subscribe(name, handler, true, eventTypes)
+}
+
+//subscribe - регистрирует подписчика и, для асинхронного, запускает его горутину
+func (s *Service) subscribe(name string, handler EventHandler, async bool, eventTypes []EventType) error {
+	if name == "" || strings.ContainsAny(name, ";|,") {
+		return ErrInvalidSubscriber
+	}
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	for _, sub := range s.subscribers {
+		if sub.name == name {
+			return ErrSubscriberExists
+		}
+	}
+
+	sub := &subscriber{name: name, handler: handler, types: map[EventType]bool{}}
+	for _, eventType := range eventTypes {
+		sub.types[eventType] = true
+	}
+	if async {
+		sub.queue = make(chan *outboxEvent, eventQueueSize)
+		go s.consume(sub)
+	}
+	s.subscribers = append(s.subscribers, sub)
+	return nil
+}
+
+//Unsubscribe - отписывает подписчика. Недоставленные ему события остаются в outbox.
+func (s *Service) Unsubscribe(name string) {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	for i, sub := range s.subscribers {
+		if sub.name == name {
+			s.subscribers = append(s.subscribers[:i], s.subscribers[i+1:]...)
+			if sub.queue != nil {
+				close(sub.queue)
+			}
+			return
+		}
+	}
+}
+
+//consume - горутина асинхронного подписчика
+func (s *Service) consume(sub *subscriber) {
+	for item := range sub.queue {
+		err := sub.handler(item.event)
+		s.ackEvent(item, sub.name, err == nil)
+		s.eventsWG.Done()
+	}
+}
+
+//WaitEvents - ждёт, пока асинхронные подписчики обработают уже отправленные им события
+func (s *Service) WaitEvents() {
+	s.eventsWG.Wait()
+}
+
+//publish - сохраняет событие в outbox и доставляет его подписчикам
+func (s *Service) publish(eventType EventType, account *types.Account, event Event) {
+	event.ID = uuid.New().String()
+	event.Type = eventType
+	event.Time = s.now()
+	event.AccountID = account.ID
+	event.Phone = account.Phone
+	event.Balance = account.Balance
+
+	s.eventsMu.Lock()
+	item := &outboxEvent{event: event, pending: map[string]bool{}}
+	for _, sub := range s.subscribers {
+		if len(sub.types) == 0 || sub.types[eventType] {
+			item.pending[sub.name] = false
+		}
+	}
+	if len(item.pending) == 0 {
+		s.eventsMu.Unlock()
+		return
+	}
+	s.outbox = append(s.outbox, item)
+	s.eventsMu.Unlock()
+
+	s.dispatch([]*outboxEvent{item})
+}
+
+//DeliverEvents - повторно доставляет события из outbox, которые не были доставлены
+//(обработчик вернул ошибку, очередь была переполнена или событие загружено Import).
+//Возвращает, сколько доставок ещё не выполнено.
+func (s *Service) DeliverEvents() int {
+	s.eventsMu.Lock()
+	items := make([]*outboxEvent, len(s.outbox))
+	copy(items, s.outbox)
+	s.eventsMu.Unlock()
+
+	s.dispatch(items)
+
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	pending := 0
+	for _, item := range s.outbox {
+		pending += len(item.pending)
+	}
+	return pending
+}
+
+//dispatch - отдаёт события подписчикам, которым они ещё не доставлены и сейчас не доставляются
+func (s *Service) dispatch(items []*outboxEvent) {
+	type delivery struct {
+		item *outboxEvent
+		sub  *subscriber
+	}
+	deliveries := []delivery{}
+	s.eventsMu.Lock()
+	for _, item := range items {
+		for _, sub := range s.subscribers {
+			inflight, ok := item.pending[sub.name]
+			if !ok || inflight {
+				continue
+			}
+			if sub.queue == nil {
+				item.pending[sub.name] = true
+				deliveries = append(deliveries, delivery{item, sub})
+				continue
+			}
+			//переполненная очередь не блокирует операцию, событие подождёт DeliverEvents
+			select {
+			case sub.queue <- item:
+				item.pending[sub.name] = true
+				s.eventsWG.Add(1)
+			default:
+			}
+		}
+	}
+	s.eventsMu.Unlock()
+
+	//синхронные обработчики вызываются без блокировки, чтобы они могли обращаться к сервису
+	for _, d := range deliveries {
+		err := d.sub.handler(d.item.event)
+		s.ackEvent(d.item, d.sub.name, err == nil)
+	}
+}
+
+//ackEvent - отмечает результат доставки. Доставленное всем подписчикам событие удаляется из outbox.
+func (s *Service) ackEvent(item *outboxEvent, name string, delivered bool) {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	if !delivered {
+		item.pending[name] = false
+		return
+	}
+	delete(item.pending, name)
+	if len(item.pending) != 0 {
+		return
+	}
+	for i, saved := range s.outbox {
+		if saved == item {
+			s.outbox = append(s.outbox[:i], s.outbox[i+1:]...)
+			return
+		}
+	}
+}
+
+//encodeEvent - сериализует событие outbox:
+//ID;тип;время;подписчики;аккаунт;телефон;баланс;вид записи;поля записи
+func encodeEvent(item *outboxEvent) string {
+	names := make([]string, 0, len(item.pending))
+	for name := range item.pending {
+		names = append(names, name)
+	}
+	event := item.event
+	record := ""
+	switch {
+	case event.Account != nil:
+		record = "account;" + encodeAccount(event.Account)
+	case event.Deposit != nil:
+		record = "deposit;" + encodeDeposit(event.Deposit)
+	case event.Payment != nil:
+		record = "payment;" + encodePayment(event.Payment)
+	case event.Favorite != nil:
+		record = "favorite;" + encodeFavorite(event.Favorite)
+	}
+	return event.ID + ";" + string(event.Type) + ";" + encodeTime(event.Time) + ";" +
+		strings.Join(names, ",") + ";" + strconv.FormatInt(event.AccountID, 10) + ";" +
+		string(event.Phone) + ";" + strconv.FormatInt(int64(event.Balance), 10) + ";" + record
+}
+
+//decodeEvent - восстанавливает событие outbox из полей строки дампа
+func decodeEvent(value []string) (*outboxEvent, error) {
+	if len(value) < 9 {
+		return nil, ErrInvalidDump
+	}
+	eventTime, err := decodeTime(value[2])
+	if err != nil {
+		return nil, err
+	}
+	accountID, err := strconv.ParseInt(value[4], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := strconv.ParseInt(value[6], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &outboxEvent{
+		event: Event{
+			ID:        value[0],
+			Type:      EventType(value[1]),
+			Time:      eventTime,
+			AccountID: accountID,
+			Phone:     types.Phone(value[5]),
+			Balance:   types.Money(balance),
+		},
+		pending: map[string]bool{},
+	}
+	if value[3] != "" {
+		for _, name := range strings.Split(value[3], ",") {
+			item.pending[name] = false
+		}
+	}
+
+	switch value[7] {
+	case "account":
+		item.event.Account, err = decodeAccount(value[8:])
+	case "deposit":
+		item.event.Deposit, err = decodeDeposit(value[8:])
+	case "payment":
+		item.event.Payment, err = decodePayment(value[8:])
+	case "favorite":
+		item.event.Favorite, err = decodeFavorite(value[8:])
+	default:
+		err = ErrInvalidDump
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+//exportOutbox - сохраняет недоставленные события в dir/outbox.dump
+func (s *Service) exportOutbox(dir string) error {
+	s.eventsMu.Lock()
+	records := make([]string, 0, len(s.outbox))
+	for _, item := range s.outbox {
+		records = append(records, encodeEvent(item))
+	}
+	s.eventsMu.Unlock()
+	return writeDump(dir, "outbox.dump", records)
+}
+
+//importOutbox - загружает недоставленные события из dir/outbox.dump
+func (s *Service) importOutbox(dir string) error {
+	values, err := readDump(dir, "outbox.dump")
+	if err != nil {
+		return err
+	}
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	for _, value := range values {
+		item, err := decodeEvent(value)
+		if err != nil {
+			return err
+		}
+		s.outbox = append(s.outbox, item)
+	}
+	return nil
+}
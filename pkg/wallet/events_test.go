@@ -0,0 +1,197 @@
+package wallet
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+//eventRecorder - подписчик, запоминающий полученные события
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+	fail   bool
+}
+
+func (r *eventRecorder) handle(event Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail {
+		return errors.New("sms gateway is unavailable")
+	}
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *eventRecorder) types() []EventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	got := []EventType{}
+	for _, event := range r.events {
+		got = append(got, event.Type)
+	}
+	return got
+}
+
+func TestService_Subscribe_sync(t *testing.T) {
+	svc := &Service{}
+	svc.SetClock(newTestClock().Now)
+	all := &eventRecorder{}
+	payments := &eventRecorder{}
+	err := svc.Subscribe("audit", all.handle)
+	if err != nil {
+		t.Fatalf("Subscribe(): error = %v", err)
+	}
+	err = svc.Subscribe("sms", payments.handle, EventPaymentCreated, EventPaymentRejected)
+	if err != nil {
+		t.Fatalf("Subscribe(): error = %v", err)
+	}
+	err = svc.Subscribe("sms", payments.handle)
+	if err != ErrSubscriberExists {
+		t.Errorf("Subscribe(): must return ErrSubscriberExists, returned = %v", err)
+	}
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Deposit(account.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	favorite, err := svc.CreateFavorite(account.ID, "Megafon", "phone", 10_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := svc.PayFromFavorite(favorite.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []EventType{EventAccountRegistered, EventDeposited, EventFavoriteCreated, EventPaymentCreated, EventPaymentRejected}
+	if got := all.types(); !reflect.DeepEqual(want, got) {
+		t.Errorf("Subscribe(): want => %v got => %v", want, got)
+	}
+	if len(payments.events) != 2 {
+		t.Fatalf("Subscribe(): filtered subscriber got = %v", payments.events)
+	}
+	created := payments.events[0]
+	if created.Phone != account.Phone || created.Balance != 90_00 || created.Payment.ID != payment.ID ||
+		created.Payment.Status != types.PaymentStatusInProgress {
+		t.Errorf("Subscribe(): wrong event = %v", created)
+	}
+	if svc.DeliverEvents() != 0 || len(svc.outbox) != 0 {
+		t.Errorf("DeliverEvents(): delivered events must leave outbox")
+	}
+}
+
+func TestService_DeliverEvents_retry(t *testing.T) {
+	svc := &Service{}
+	sms := &eventRecorder{fail: true}
+	svc.Subscribe("sms", sms.handle, EventDeposited)
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Deposit(account.ID, 100_00)
+	if err != nil {
+		t.Fatalf("Deposit(): failed subscriber must not fail operation, error = %v", err)
+	}
+	if svc.DeliverEvents() != 1 {
+		t.Errorf("DeliverEvents(): event must stay pending")
+	}
+
+	sms.fail = false
+	if svc.DeliverEvents() != 0 {
+		t.Errorf("DeliverEvents(): event must be delivered")
+	}
+	if got := sms.types(); !reflect.DeepEqual([]EventType{EventDeposited}, got) {
+		t.Errorf("DeliverEvents(): got => %v", got)
+	}
+}
+
+func TestService_SubscribeAsync(t *testing.T) {
+	svc := &Service{}
+	sms := &eventRecorder{}
+	err := svc.SubscribeAsync("sms", sms.handle, EventPaymentCreated)
+	if err != nil {
+		t.Fatalf("SubscribeAsync(): error = %v", err)
+	}
+	defer svc.Unsubscribe("sms")
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Deposit(account.ID, 100_00)
+	for i := 0; i < 3; i++ {
+		_, err = svc.Pay(account.ID, 10_00, "auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc.WaitEvents()
+
+	want := []EventType{EventPaymentCreated, EventPaymentCreated, EventPaymentCreated}
+	if got := sms.types(); !reflect.DeepEqual(want, got) {
+		t.Errorf("SubscribeAsync(): want => %v got => %v", want, got)
+	}
+	if svc.DeliverEvents() != 0 {
+		t.Errorf("DeliverEvents(): nothing must be pending")
+	}
+}
+
+func TestService_Outbox_exportImport(t *testing.T) {
+	svc := &Service{}
+	sms := &eventRecorder{fail: true}
+	svc.Subscribe("sms", sms.handle, EventPaymentCreated)
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Deposit(account.ID, 100_00)
+	payment, err := svc.Pay(account.ID, 10_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//после перезапуска подписчик с тем же именем получает недоставленное событие
+	restarted := &eventRecorder{}
+	imported.Subscribe("sms", restarted.handle, EventPaymentCreated)
+	if imported.DeliverEvents() != 0 {
+		t.Errorf("DeliverEvents(): imported event must be delivered")
+	}
+	if len(restarted.events) != 1 || restarted.events[0].Payment.ID != payment.ID ||
+		restarted.events[0].Phone != account.Phone {
+		t.Errorf("DeliverEvents(): wrong events = %v", restarted.events)
+	}
+
+	err = imported.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := &Service{}
+	empty.Import(dir)
+	if len(empty.outbox) != 0 {
+		t.Errorf("Export(): delivered events must not be exported = %v", empty.outbox)
+	}
+}
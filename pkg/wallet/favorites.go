@@ -0,0 +1,142 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidFavoriteName = errors.New("favorite name must not be empty")
+var ErrInvalidFavoriteNameChars = errors.New("favorite name must not contain ';' or '|'")
+var ErrFavoriteNameExists = errors.New("favorite with this name already exists")
+var ErrInvalidFavoriteCategory = errors.New("favorite category must not be empty")
+var ErrFavoriteAmountRequired = errors.New("favorite has no amount, amount must be given")
+
+//CreateFavorite - создаёт избранное без предварительного платежа.
+//amount - сумма по умолчанию, 0 - суммы нет и её нужно передавать в PayFromFavoriteAmount.
+func (s *Service) CreateFavorite(accountID int64, name string, category types.PaymentCategory, amount types.Money) (*types.Favorite, error) {
+	if amount < 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	if category == "" {
+		return nil, ErrInvalidFavoriteCategory
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	name, err = s.checkFavoriteName(accountID, "", name)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	favorite := &types.Favorite{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Amount:    amount,
+		Category:  category,
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.favorites = append(s.favorites, favorite)
+	s.recordFavorite(OperationFavorite, favorite)
+	copyFavorite := *favorite
+	s.publish(EventFavoriteCreated, account, Event{Favorite: &copyFavorite})
+	return favorite, nil
+}
+
+//FindFavoriteByID - находит избранное по идентификатору
+func (s *Service) FindFavoriteByID(favoriteID string) (*types.Favorite, error) {
+	for _, favorite := range s.favorites {
+		if favorite.ID == favoriteID {
+			return favorite, nil
+		}
+	}
+	return nil, ErrFavoriteNotFound
+}
+
+//ListFavorites - избранное аккаунта в порядке создания
+func (s *Service) ListFavorites(accountID int64) ([]types.Favorite, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	favorites := []types.Favorite{}
+	for _, favorite := range s.favorites {
+		if favorite.AccountID == accountID {
+			favorites = append(favorites, *favorite)
+		}
+	}
+	return favorites, nil
+}
+
+//UpdateFavorite - меняет название и сумму избранного (0 - без суммы по умолчанию)
+func (s *Service) UpdateFavorite(favoriteID string, name string, amount types.Money) (*types.Favorite, error) {
+	if amount < 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+	name, err = s.checkFavoriteName(favorite.AccountID, favoriteID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	favorite.Name = name
+	favorite.Amount = amount
+	favorite.UpdatedAt = s.now()
+	s.recordFavorite(OperationFavoriteUpdate, favorite)
+	return favorite, nil
+}
+
+//RenameFavorite - меняет только название избранного
+func (s *Service) RenameFavorite(favoriteID string, name string) (*types.Favorite, error) {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+	return s.UpdateFavorite(favoriteID, name, favorite.Amount)
+}
+
+//DeleteFavorite - удаляет избранное вместе с его расписанием.
+//История выполнений по расписанию остаётся.
+func (s *Service) DeleteFavorite(favoriteID string) error {
+	for i, favorite := range s.favorites {
+		if favorite.ID != favoriteID {
+			continue
+		}
+		s.favorites = append(s.favorites[:i], s.favorites[i+1:]...)
+		s.Unschedule(favoriteID)
+		favorite.UpdatedAt = s.now()
+		s.recordFavorite(OperationFavoriteDelete, favorite)
+		return nil
+	}
+	return ErrFavoriteNotFound
+}
+
+//checkFavoriteName - проверяет название избранного: оно не пустое, не содержит ';' и '|'
+//и не совпадает (без учёта регистра и пробелов по краям) с другим избранным того же аккаунта.
+//Возвращает название без пробелов по краям.
+func (s *Service) checkFavoriteName(accountID int64, favoriteID string, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrInvalidFavoriteName
+	}
+	//';' и '|' - разделители полей и записей в дампах и журнале
+	if strings.ContainsAny(name, ";|") {
+		return "", ErrInvalidFavoriteNameChars
+	}
+	for _, favorite := range s.favorites {
+		if favorite.AccountID == accountID && favorite.ID != favoriteID && strings.EqualFold(favorite.Name, name) {
+			return "", ErrFavoriteNameExists
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,314 @@
+package wallet
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+//addTestFavorite - платёж на amount и избранное name из него
+func (s *testServiceUser) addTestFavorite(t *testing.T, accountID int64, amount types.Money, name string) *types.Favorite {
+	t.Helper()
+	payment, err := s.Pay(accountID, amount, "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	favorite, err := s.FavoritePayment(payment.ID, name)
+	if err != nil {
+		t.Fatalf("FavoritePayment(): error = %v", err)
+	}
+	return favorite
+}
+
+func TestService_ListFavorites_success(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	other, err := svc.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Deposit(other.ID, 100_00)
+
+	tcell := svc.addTestFavorite(t, account.ID, 10_00, "Tcell")
+	babilon := svc.addTestFavorite(t, account.ID, 20_00, "Babilon")
+	svc.addTestFavorite(t, other.ID, 30_00, "Tcell")
+
+	favorites, err := svc.ListFavorites(account.ID)
+	if err != nil {
+		t.Fatalf("ListFavorites(): error = %v", err)
+	}
+	want := []types.Favorite{*tcell, *babilon}
+	if !reflect.DeepEqual(want, favorites) {
+		t.Errorf("ListFavorites(): want => %v got => %v", want, favorites)
+	}
+
+	_, err = svc.ListFavorites(100)
+	if err != ErrAccountNotFound {
+		t.Errorf("ListFavorites(): must return ErrAccountNotFound, returned = %v", err)
+	}
+}
+
+func TestService_FavoriteName_unique(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	svc.addTestFavorite(t, account.ID, 10_00, "Tcell")
+	babilon := svc.addTestFavorite(t, account.ID, 20_00, "  Babilon ")
+	if babilon.Name != "Babilon" {
+		t.Errorf("FavoritePayment(): name must be trimmed, got = %q", babilon.Name)
+	}
+
+	payment, err := svc.Pay(account.ID, 10_00, "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.FavoritePayment(payment.ID, " tcell")
+	if err != ErrFavoriteNameExists {
+		t.Errorf("FavoritePayment(): must return ErrFavoriteNameExists, returned = %v", err)
+	}
+	_, err = svc.FavoritePayment(payment.ID, " ")
+	if err != ErrInvalidFavoriteName {
+		t.Errorf("FavoritePayment(): must return ErrInvalidFavoriteName, returned = %v", err)
+	}
+	_, err = svc.RenameFavorite(babilon.ID, "TCELL")
+	if err != ErrFavoriteNameExists {
+		t.Errorf("RenameFavorite(): must return ErrFavoriteNameExists, returned = %v", err)
+	}
+	//своё же название не мешает
+	_, err = svc.RenameFavorite(babilon.ID, "babilon")
+	if err != nil {
+		t.Errorf("RenameFavorite(): error = %v", err)
+	}
+}
+
+func TestService_UpdateFavorite_success(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 1_000_00)
+	favorite := svc.addTestFavorite(t, account.ID, 10_00, "Tcell")
+
+	clock.Add(1000)
+	updated, err := svc.UpdateFavorite(favorite.ID, "Megafon", 15_00)
+	if err != nil {
+		t.Fatalf("UpdateFavorite(): error = %v", err)
+	}
+	if updated.Name != "Megafon" || updated.Amount != 15_00 || !updated.UpdatedAt.Equal(clock.Now()) {
+		t.Errorf("UpdateFavorite(): wrong favorite = %v", updated)
+	}
+
+	payment, err := svc.PayFromFavorite(favorite.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Amount != 15_00 {
+		t.Errorf("PayFromFavorite(): must use new amount, got = %v", payment.Amount)
+	}
+
+	_, err = svc.UpdateFavorite(favorite.ID, "Megafon", -1)
+	if err != ErrAmountMustBePositive {
+		t.Errorf("UpdateFavorite(): must return ErrAmountMustBePositive, returned = %v", err)
+	}
+	_, err = svc.UpdateFavorite("unknown", "Megafon", 10_00)
+	if err != ErrFavoriteNotFound {
+		t.Errorf("UpdateFavorite(): must return ErrFavoriteNotFound, returned = %v", err)
+	}
+}
+
+func TestService_DeleteFavorite_success(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	favorite := svc.addTestFavorite(t, account.ID, 10_00, "Tcell")
+	_, err := svc.ScheduleFavorite(favorite.ID, "daily 09:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.DeleteFavorite(favorite.ID)
+	if err != nil {
+		t.Fatalf("DeleteFavorite(): error = %v", err)
+	}
+	_, err = svc.FindFavoriteByID(favorite.ID)
+	if err != ErrFavoriteNotFound {
+		t.Errorf("FindFavoriteByID(): must return ErrFavoriteNotFound, returned = %v", err)
+	}
+	_, err = svc.FindSchedule(favorite.ID)
+	if err != ErrScheduleNotFound {
+		t.Errorf("DeleteFavorite(): schedule must be removed, returned = %v", err)
+	}
+	err = svc.DeleteFavorite(favorite.ID)
+	if err != ErrFavoriteNotFound {
+		t.Errorf("DeleteFavorite(): must return ErrFavoriteNotFound, returned = %v", err)
+	}
+
+	//название освободилось
+	svc.addTestFavorite(t, account.ID, 10_00, "Tcell")
+	_, err = svc.PayFromFavorite(favorite.ID)
+	if err != ErrFavoriteNotFound {
+		t.Errorf("PayFromFavorite(): must return ErrFavoriteNotFound, returned = %v", err)
+	}
+}
+
+func TestService_DeleteFavorite_exportAndRestore(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 1_000_00)
+	favorite := svc.addTestFavorite(t, account.ID, 10_00, "Tcell")
+
+	dir := t.TempDir()
+	err := svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Snapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Add(1000)
+	err = svc.DeleteFavorite(favorite.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(filepath.Join(dir, "favorites.dump"))
+	if !os.IsNotExist(err) {
+		t.Errorf("Export(): favorites.dump must be removed, stat error = %v", err)
+	}
+
+	//удаление проигрывается из журнала поверх снимка, где избранное ещё есть
+	err = svc.FlushJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := RestoreAt(dir, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = restored.FindFavoriteByID(favorite.ID)
+	if err != ErrFavoriteNotFound {
+		t.Errorf("RestoreAt(): deleted favorite must not be restored, returned = %v", err)
+	}
+}
+
+func TestService_CreateFavorite_success(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+
+	favorite, err := svc.CreateFavorite(account.ID, "Electricity", "utilities", 0)
+	if err != nil {
+		t.Fatalf("CreateFavorite(): error = %v", err)
+	}
+	_, err = svc.PayFromFavorite(favorite.ID)
+	if err != ErrFavoriteAmountRequired {
+		t.Errorf("PayFromFavorite(): must return ErrFavoriteAmountRequired, returned = %v", err)
+	}
+	_, err = svc.ScheduleFavorite(favorite.ID, "monthly 1 09:00")
+	if err != ErrFavoriteAmountRequired {
+		t.Errorf("ScheduleFavorite(): must return ErrFavoriteAmountRequired, returned = %v", err)
+	}
+
+	payment, err := svc.PayFromFavoriteAmount(favorite.ID, 123_45)
+	if err != nil {
+		t.Fatalf("PayFromFavoriteAmount(): error = %v", err)
+	}
+	if payment.Amount != 123_45 || payment.Category != "utilities" || account.Balance != 876_55 {
+		t.Errorf("PayFromFavoriteAmount(): wrong payment = %v, balance = %v", payment, account.Balance)
+	}
+
+	water, err := svc.CreateFavorite(account.ID, "Water", "utilities", 50_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err = svc.PayFromFavoriteAmount(water.ID, 70_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Amount != 70_00 || water.Amount != 50_00 {
+		t.Errorf("PayFromFavoriteAmount(): override must not change favorite, payment = %v, favorite = %v", payment, water)
+	}
+
+	for _, amount := range []types.Money{0, -1_00} {
+		_, err = svc.PayFromFavoriteAmount(water.ID, amount)
+		if err != ErrAmountMustBePositive {
+			t.Errorf("PayFromFavoriteAmount(%v): must return ErrAmountMustBePositive, returned = %v", amount, err)
+		}
+	}
+	if account.Balance != 806_55 {
+		t.Errorf("PayFromFavoriteAmount(): rejected amounts must not change balance = %v", account.Balance)
+	}
+}
+
+func TestService_CreateFavorite_fail(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+
+	_, err := svc.CreateFavorite(100, "Water", "utilities", 0)
+	if err != ErrAccountNotFound {
+		t.Errorf("CreateFavorite(): must return ErrAccountNotFound, returned = %v", err)
+	}
+	_, err = svc.CreateFavorite(account.ID, "Water", "", 0)
+	if err != ErrInvalidFavoriteCategory {
+		t.Errorf("CreateFavorite(): must return ErrInvalidFavoriteCategory, returned = %v", err)
+	}
+	_, err = svc.CreateFavorite(account.ID, "Water", "utilities", -1)
+	if err != ErrAmountMustBePositive {
+		t.Errorf("CreateFavorite(): must return ErrAmountMustBePositive, returned = %v", err)
+	}
+}
+
+func TestService_FavoriteName_separators(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 1_000_00)
+
+	_, err := svc.CreateFavorite(account.ID, "Water;utilities", "utilities", 0)
+	if err != ErrInvalidFavoriteNameChars {
+		t.Errorf("CreateFavorite(): must return ErrInvalidFavoriteNameChars, returned = %v", err)
+	}
+	favorite, err := svc.CreateFavorite(account.ID, "Water, cold & hot", "utilities", 10_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.RenameFavorite(favorite.ID, "Water|1")
+	if err != ErrInvalidFavoriteNameChars {
+		t.Errorf("RenameFavorite(): must return ErrInvalidFavoriteNameChars, returned = %v", err)
+	}
+	payment, err := svc.Pay(account.ID, 5_00, "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.FavoritePayment(payment.ID, "Tcell;Megafon")
+	if err != ErrInvalidFavoriteNameChars {
+		t.Errorf("FavoritePayment(): must return ErrInvalidFavoriteNameChars, returned = %v", err)
+	}
+
+	dir := t.TempDir()
+	_, err = svc.Snapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(1000)
+	_, err = svc.RenameFavorite(favorite.ID, "Water: cold, hot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.FlushJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := RestoreAt(dir, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range []*Service{imported, restored} {
+		saved, err := got.FindFavoriteByID(favorite.ID)
+		if err != nil || saved.Name != favorite.Name || saved.Amount != favorite.Amount ||
+			!saved.UpdatedAt.Equal(favorite.UpdatedAt) {
+			t.Errorf("favorite must survive dump round trip, want => %v got => %v, error = %v", favorite, saved, err)
+		}
+	}
+}
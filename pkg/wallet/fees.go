@@ -0,0 +1,200 @@
+package wallet
+
+import (
+	"strconv"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+//FeeCategory - категория записей-комиссий. Комиссия хранится отдельным платежом
+//с ParentID платежа, за который она взята.
+const FeeCategory types.PaymentCategory = "fee"
+
+//isFee - запись комиссии за платёж, а не самостоятельный платёж
+func isFee(payment *types.Payment) bool {
+	return payment.ParentID != "" && payment.Category == FeeCategory
+}
+
+//SetFeeRule - задаёт правило комиссии, заменяя правило для той же категории и уровня.
+//Категория приводится к коду из справочника.
+func (s *Service) SetFeeRule(rule types.FeeRule) error {
+	if rule.Percent < 0 || rule.Fixed < 0 || rule.Min < 0 || rule.Max < 0 || rule.Max != 0 && rule.Max < rule.Min {
+		return ErrAmountMustBePositive
+	}
+	if rule.Tier != "" {
+		if _, ok := tierRanks[rule.Tier]; !ok {
+			return ErrInvalidTier
+		}
+	}
+
+	index := s.categoryIndex()
+	rule.Category = canonicalCategory(index, rule.Category)
+	for i, saved := range s.fees {
+		if sameCategory(index, saved.Category, rule.Category) && saved.Tier == rule.Tier {
+			s.fees[i] = &rule
+			return nil
+		}
+	}
+	s.fees = append(s.fees, &rule)
+	return nil
+}
+
+//SetKeepFeesOnReject - оставлять ли комиссию при отмене платежа (Reject).
+//По умолчанию комиссия возвращается вместе с платежом. При частичном возврате (Refund)
+//комиссия не возвращается никогда.
+func (s *Service) SetKeepFeesOnReject(keep bool) {
+	s.keepFeesOnReject = keep
+}
+
+//findFeeRule - самое точное правило: категория и уровень, затем только категория,
+//затем только уровень, затем общее правило. Синонимы категорий считаются одной категорией.
+func (s *Service) findFeeRule(category types.PaymentCategory, tier types.VerificationTier) *types.FeeRule {
+	var found *types.FeeRule
+	best := -1
+	index := s.categoryIndex()
+	for _, rule := range s.fees {
+		if rule.Category != "" && !sameCategory(index, rule.Category, category) || rule.Tier != "" && rule.Tier != tier {
+			continue
+		}
+		rank := 0
+		if rule.Category != "" {
+			rank += 2
+		}
+		if rule.Tier != "" {
+			rank++
+		}
+		if rank > best {
+			found, best = rule, rank
+		}
+	}
+	return found
+}
+
+//Fee - комиссия, которая будет взята с аккаунта за платёж amount в категории category
+func (s *Service) Fee(accountID int64, amount types.Money, category types.PaymentCategory) (types.Money, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return 0, err
+	}
+	return s.fee(account, amount, category), nil
+}
+
+//fee - расчёт комиссии по правилу
+func (s *Service) fee(account *types.Account, amount types.Money, category types.PaymentCategory) types.Money {
+	rule := s.findFeeRule(category, tierOf(account))
+	if rule == nil {
+		return 0
+	}
+	fee := rule.Fixed + types.Money(int64(amount)*rule.Percent/10_000)
+	if fee < rule.Min {
+		fee = rule.Min
+	}
+	if rule.Max != 0 && fee > rule.Max {
+		fee = rule.Max
+	}
+	return fee
+}
+
+//chargeFee - создаёт запись комиссии за платёж; баланс уже должен быть уменьшен вызывающим
+func (s *Service) chargeFee(payment *types.Payment, fee types.Money, operation Operation) *types.Payment {
+	if fee == 0 {
+		return nil
+	}
+	charge := &types.Payment{
+		ID:        uuid.New().String(),
+		AccountID: payment.AccountID,
+		Amount:    fee,
+		Category:  FeeCategory,
+		Status:    payment.Status,
+		CreatedAt: payment.CreatedAt,
+		UpdatedAt: payment.UpdatedAt,
+		ParentID:  payment.ID,
+	}
+	s.payments = append(s.payments, charge)
+	s.recordPayment(operation, charge)
+	return charge
+}
+
+//FindFee - комиссия, взятая за платёж
+func (s *Service) FindFee(paymentID string) (*types.Payment, error) {
+	for _, payment := range s.payments {
+		if isFee(payment) && payment.ParentID == paymentID {
+			return payment, nil
+		}
+	}
+	return nil, ErrPaymentNotFound
+}
+
+//rejectFee - при отмене платежа возвращает комиссию, если это не запрещено SetKeepFeesOnReject.
+//Возвращает сумму, которую нужно зачислить на счёт.
+func (s *Service) rejectFee(payment *types.Payment) types.Money {
+	if s.keepFeesOnReject {
+		return 0
+	}
+	fee, err := s.FindFee(payment.ID)
+	if err != nil || fee.Status == types.PaymentStatusFail {
+		return 0
+	}
+	fee.Status = types.PaymentStatusFail
+	fee.UpdatedAt = payment.UpdatedAt
+	s.recordPayment(OperationReject, fee)
+	return fee.Amount
+}
+
+//encodeFeeRule - сериализует правило: категория;уровень;процент;фикс;мин;макс
+func encodeFeeRule(rule *types.FeeRule) string {
+	return string(rule.Category) + ";" + string(rule.Tier) + ";" +
+		strconv.FormatInt(rule.Percent, 10) + ";" +
+		strconv.FormatInt(int64(rule.Fixed), 10) + ";" +
+		strconv.FormatInt(int64(rule.Min), 10) + ";" +
+		strconv.FormatInt(int64(rule.Max), 10)
+}
+
+//decodeFeeRule - восстанавливает правило из полей строки дампа
+func decodeFeeRule(value []string) (*types.FeeRule, error) {
+	if len(value) < 6 {
+		return nil, ErrInvalidDump
+	}
+	numbers := make([]int64, 4)
+	for i := range numbers {
+		number, err := strconv.ParseInt(value[i+2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		numbers[i] = number
+	}
+	return &types.FeeRule{
+		Category: types.PaymentCategory(value[0]),
+		Tier:     types.VerificationTier(value[1]),
+		Percent:  numbers[0],
+		Fixed:    types.Money(numbers[1]),
+		Min:      types.Money(numbers[2]),
+		Max:      types.Money(numbers[3]),
+	}, nil
+}
+
+//exportFees - сохраняет правила комиссий в dir/fees.dump
+func (s *Service) exportFees(dir string) error {
+	records := make([]string, 0, len(s.fees))
+	for _, rule := range s.fees {
+		records = append(records, encodeFeeRule(rule))
+	}
+	return writeDump(dir, "fees.dump", records)
+}
+
+//importFees - загружает правила комиссий из dir/fees.dump
+func (s *Service) importFees(dir string) error {
+	values, err := readDump(dir, "fees.dump")
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		rule, err := decodeFeeRule(value)
+		if err != nil {
+			return err
+		}
+		s.fees = append(s.fees, rule)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func TestService_Fee_rules(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	for _, rule := range []types.FeeRule{
+		{Fixed: 1_00},
+		{Category: "auto", Percent: 100, Min: 50, Max: 5_00},
+		{Tier: types.TierFull},
+		{Category: "auto", Tier: types.TierFull, Percent: 50},
+	} {
+		svc.SetFeeRule(rule)
+	}
+
+	tests := []struct {
+		amount   types.Money
+		category types.PaymentCategory
+		want     types.Money
+	}{
+		{100_00, "food", 1_00},
+		{100_00, "auto", 1_00},
+		{10_00, "auto", 50},
+		{1_000_00, "auto", 5_00},
+	}
+	for _, test := range tests {
+		got, err := svc.Fee(account.ID, test.amount, test.category)
+		if err != nil || got != test.want {
+			t.Errorf("Fee(%v, %v): want => %v got => %v, error = %v", test.amount, test.category, test.want, got, err)
+		}
+	}
+
+	svc.SetProfile(account.ID, "Ali Valiev", "A1234567")
+	svc.UpgradeTier(account.ID, types.TierFull)
+	if got, _ := svc.Fee(account.ID, 100_00, "food"); got != 0 {
+		t.Errorf("Fee(): tier rule must win over default, got = %v", got)
+	}
+	if got, _ := svc.Fee(account.ID, 100_00, "auto"); got != 50 {
+		t.Errorf("Fee(): category and tier rule must win, got = %v", got)
+	}
+}
+
+func TestService_Pay_chargesFee(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	svc.SetFeeRule(types.FeeRule{Category: "auto", Percent: 100})
+
+	payment, err := svc.Pay(account.ID, 500_00, "auto")
+	if err != nil {
+		t.Fatalf("Pay(): error = %v", err)
+	}
+	if account.Balance != 495_00 {
+		t.Errorf("Pay(): fee must be debited, balance = %v", account.Balance)
+	}
+	fee, err := svc.FindFee(payment.ID)
+	if err != nil {
+		t.Fatalf("FindFee(): error = %v", err)
+	}
+	if fee.Amount != 5_00 || fee.Category != FeeCategory || fee.ParentID != payment.ID {
+		t.Errorf("FindFee(): wrong fee = %v", fee)
+	}
+
+	history, err := svc.ExportAccountHistory(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].ID != payment.ID || history[1].ID != fee.ID {
+		t.Errorf("ExportAccountHistory(): fee must follow payment, history = %v", history)
+	}
+
+	//баланса хватает на платёж, но не на платёж с комиссией
+	_, err = svc.Pay(account.ID, 495_00, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("Pay(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+}
+
+func TestService_Reject_feePolicy(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	svc.SetFeeRule(types.FeeRule{Fixed: 2_00})
+
+	payment, err := svc.Pay(account.ID, 100_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee, _ := svc.FindFee(payment.ID)
+	if account.Balance != 1_000_00 || fee.Status != types.PaymentStatusFail {
+		t.Errorf("Reject(): fee must be refunded, balance = %v, fee = %v", account.Balance, fee)
+	}
+
+	svc.SetKeepFeesOnReject(true)
+	payment, err = svc.Pay(account.ID, 100_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 998_00 {
+		t.Errorf("Reject(): fee must be kept, balance = %v", account.Balance)
+	}
+}
+
+func TestService_Transfer_chargesFee(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	svc.SetFeeRule(types.FeeRule{Category: TransferCategory, Fixed: 3_00})
+	other, err := svc.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.Transfer(account.ID, other.ID, 100_00)
+	if err != nil {
+		t.Fatalf("Transfer(): error = %v", err)
+	}
+	if account.Balance != 897_00 || other.Balance != 100_00 {
+		t.Errorf("Transfer(): wrong balances = %v, %v", account.Balance, other.Balance)
+	}
+	fee, err := svc.FindFee(payment.ID)
+	if err != nil || fee.Amount != 3_00 {
+		t.Errorf("FindFee(): wrong fee = %v, error = %v", fee, err)
+	}
+}
+
+func TestService_Fees_exportImport(t *testing.T) {
+	rules := []types.FeeRule{
+		{Fixed: 1_00},
+		{Category: "auto", Tier: types.TierBasic, Percent: 150, Min: 10, Max: 10_00},
+	}
+	svc, _, _ := newTestServiceUserWithClock(t, 1_000_00)
+	for _, rule := range rules {
+		svc.SetFeeRule(rule)
+	}
+
+	dir := t.TempDir()
+	err := svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []types.FeeRule{}
+	for _, rule := range imported.fees {
+		got = append(got, *rule)
+	}
+	if !reflect.DeepEqual(rules, got) {
+		t.Errorf("Import(): want => %v got => %v", rules, got)
+	}
+}
+
+func TestService_RejectRefund_feeLine(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	svc.SetFeeRule(types.FeeRule{Fixed: 2_00})
+	svc.SetKeepFeesOnReject(true)
+
+	payment, err := svc.Pay(account.ID, 100_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee, err := svc.FindFee(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Reject(fee.ID)
+	if err != ErrPaymentNotRefundable {
+		t.Errorf("Reject(): fee must not be rejected, returned = %v", err)
+	}
+	_, err = svc.Refund(fee.ID, 1_00, "")
+	if err != ErrPaymentNotRefundable {
+		t.Errorf("Refund(): fee must not be refunded, returned = %v", err)
+	}
+	if account.Balance != 898_00 || fee.Status != types.PaymentStatusInProgress {
+		t.Errorf("fee must stay charged, balance = %v, fee = %v", account.Balance, fee)
+	}
+}
+
+func TestService_Fees_notSpending(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	svc.SetFeeRule(types.FeeRule{Fixed: 2_00})
+	err := svc.SetLimits(types.Limits{AccountID: account.ID, Daily: 150_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 100_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//с комиссией первого платежа дневной лимит был бы превышен
+	_, err = svc.Pay(account.ID, 50_00, "auto")
+	if err != nil {
+		t.Errorf("Pay(): fees must not count toward limits, error = %v", err)
+	}
+
+	if got := svc.SumPayments(2); got != 150_00 {
+		t.Errorf("SumPayments(): want => %v got => %v", 150_00, got)
+	}
+	stats, err := svc.SpendingAnalytics(PaymentQuery{}, GroupByCategory, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Key != "auto" || stats[0].Total != 150_00 {
+		t.Errorf("SpendingAnalytics(): fees must not be spending, stats = %v", stats)
+	}
+}
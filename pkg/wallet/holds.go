@@ -0,0 +1,187 @@
+package wallet
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrPaymentNotAuthorized = errors.New("payment is not authorized")
+var ErrCaptureExceedsHold = errors.New("capture amount exceeds authorized amount")
+
+//DefaultHoldTTL - срок жизни резерва по умолчанию
+const DefaultHoldTTL = 7 * 24 * time.Hour
+
+//SetHoldTTL - задаёт, через сколько неподтверждённый резерв снимается автоматически
+func (s *Service) SetHoldTTL(ttl time.Duration) {
+	s.holdTTL = ttl
+}
+
+//Authorize - резервирует amount на счёте: учётный баланс не меняется, а доступный уменьшается.
+//Резерв нужно подтвердить (Capture) или снять (Void), иначе он истечёт через SetHoldTTL.
+func (s *Service) Authorize(accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkActive(account)
+	if err != nil {
+		return nil, err
+	}
+	category, err = s.resolveCategory(category)
+	if err != nil {
+		return nil, err
+	}
+
+	s.expireHolds()
+	if account.Available()+account.Overdraft < amount {
+		return nil, ErrNotEnoughBalance
+	}
+	err = s.checkLimits(accountID, amount, category)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkTier(account, 0, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := s.holdTTL
+	if ttl <= 0 {
+		ttl = DefaultHoldTTL
+	}
+	now := s.now()
+	account.Hold += amount
+	account.UpdatedAt = now
+	payment := &types.Payment{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Amount:    amount,
+		Category:  category,
+		Status:    types.PaymentStatusAuthorized,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	s.payments = append(s.payments, payment)
+	s.holds = append(s.holds, payment)
+	s.recordAccount(OperationAuthorize, account)
+	s.recordPayment(OperationAuthorize, payment)
+	return payment, nil
+}
+
+//Capture - списывает amount (не больше зарезервированного) по авторизованному платежу.
+//Оставшаяся часть резерва освобождается, платёж переходит в INPROGRESS с суммой amount.
+//Деньги списываются в момент подтверждения, поэтому CreatedAt платежа становится временем Capture
+//(для выписки, закрытия дня и лимитов), а комиссия по SetFeeRule берётся с суммы amount.
+func (s *Service) Capture(paymentID string, amount types.Money) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	s.expireHolds()
+
+	payment, account, err := s.findHold(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if amount > payment.Amount {
+		return nil, ErrCaptureExceedsHold
+	}
+	err = checkActive(account)
+	if err != nil {
+		return nil, err
+	}
+	//резерв покрывает только сумму платежа, комиссия должна поместиться в доступный остаток
+	fee := s.fee(account, amount, payment.Category)
+	if account.Available()+payment.Amount+account.Overdraft < amount+fee {
+		return nil, ErrNotEnoughBalance
+	}
+	err = s.checkTier(account, 0, amount+fee)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	account.Hold -= payment.Amount
+	account.Balance -= amount + fee
+	account.UpdatedAt = now
+	s.updateOverdraft(account)
+	s.addTurnover(account, amount+fee)
+	payment.Amount = amount
+	payment.Status = types.PaymentStatusInProgress
+	payment.CreatedAt = now
+	payment.UpdatedAt = now
+	payment.ExpiresAt = time.Time{}
+	s.recordAccount(OperationCapture, account)
+	s.recordPayment(OperationCapture, payment)
+	s.chargeFee(payment, fee, OperationCapture)
+	return payment, nil
+}
+
+//Void - снимает резерв по авторизованному платежу без списания
+func (s *Service) Void(paymentID string) error {
+	s.expireHolds()
+
+	payment, account, err := s.findHold(paymentID)
+	if err != nil {
+		return err
+	}
+	s.release(payment, account, OperationVoid)
+	return nil
+}
+
+//findHold - находит авторизованный платёж и его аккаунт
+func (s *Service) findHold(paymentID string) (*types.Payment, *types.Account, error) {
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if payment.Status != types.PaymentStatusAuthorized {
+		return nil, nil, ErrPaymentNotAuthorized
+	}
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return payment, account, nil
+}
+
+//release - снимает резерв и переводит платёж в VOID
+func (s *Service) release(payment *types.Payment, account *types.Account, operation Operation) {
+	now := s.now()
+	account.Hold -= payment.Amount
+	account.UpdatedAt = now
+	payment.Status = types.PaymentStatusVoid
+	payment.UpdatedAt = now
+	s.recordAccount(operation, account)
+	s.recordPayment(operation, payment)
+}
+
+//expireHolds - снимает истёкшие резервы и убирает из списка уже закрытые
+func (s *Service) expireHolds() {
+	if len(s.holds) == 0 {
+		return
+	}
+
+	now := s.now()
+	active := s.holds[:0]
+	for _, payment := range s.holds {
+		if payment.Status != types.PaymentStatusAuthorized {
+			continue
+		}
+		if !now.Before(payment.ExpiresAt) {
+			account, err := s.FindAccountByID(payment.AccountID)
+			if err == nil {
+				s.release(payment, account, OperationVoid)
+				continue
+			}
+		}
+		active = append(active, payment)
+	}
+	s.holds = active
+}
@@ -0,0 +1,177 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func TestService_AuthorizeCapture_partial(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 100_00)
+
+	payment, err := svc.Authorize(account.ID, 80_00, "hotel")
+	if err != nil {
+		t.Fatalf("Authorize(): error = %v", err)
+	}
+	if account.Balance != 100_00 || account.Available() != 20_00 {
+		t.Errorf("Authorize(): wrong account = %+v", account)
+	}
+	_, err = svc.Pay(account.ID, 30_00, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("Pay(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+
+	_, err = svc.Capture(payment.ID, 90_00)
+	if err != ErrCaptureExceedsHold {
+		t.Errorf("Capture(): must return ErrCaptureExceedsHold, returned = %v", err)
+	}
+	captured, err := svc.Capture(payment.ID, 60_00)
+	if err != nil {
+		t.Fatalf("Capture(): error = %v", err)
+	}
+	if captured.Amount != 60_00 || captured.Status != types.PaymentStatusInProgress {
+		t.Errorf("Capture(): wrong payment = %+v", captured)
+	}
+	if account.Balance != 40_00 || account.Hold != 0 || account.Available() != 40_00 {
+		t.Errorf("Capture(): wrong account = %+v", account)
+	}
+
+	_, err = svc.Capture(payment.ID, 1)
+	if err != ErrPaymentNotAuthorized {
+		t.Errorf("Capture(): must return ErrPaymentNotAuthorized, returned = %v", err)
+	}
+}
+
+func TestService_Capture_timeAndFee(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 100_00)
+	svc.SetFeeRule(types.FeeRule{Category: "hotel", Fixed: 2_00})
+	svc.SetLimits(types.Limits{AccountID: account.ID, Daily: 100_00})
+
+	payment, err := svc.Authorize(account.ID, 80_00, "hotel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Pay(account.ID, 19_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Capture(payment.ID, 80_00)
+	if err != ErrNotEnoughBalance {
+		t.Errorf("Capture(): fee must fit into available balance, returned = %v", err)
+	}
+
+	clock.Add(24 * time.Hour)
+	captured, err := svc.Capture(payment.ID, 70_00)
+	if err != nil {
+		t.Fatalf("Capture(): error = %v", err)
+	}
+	if !captured.CreatedAt.Equal(clock.Now()) {
+		t.Errorf("Capture(): CreatedAt must be capture time = %v", captured.CreatedAt)
+	}
+	fee, err := svc.FindFee(payment.ID)
+	if err != nil || fee.Amount != 2_00 || fee.Status != types.PaymentStatusInProgress || !fee.CreatedAt.Equal(clock.Now()) {
+		t.Errorf("Capture(): wrong fee = %v, error = %v", fee, err)
+	}
+	if account.Balance != 9_00 || account.Hold != 0 {
+		t.Errorf("Capture(): wrong account = %+v", account)
+	}
+
+	//списание учитывается в лимите дня подтверждения
+	svc.Deposit(account.ID, 100_00)
+	_, err = svc.Pay(account.ID, 31_00, "auto")
+	if _, ok := err.(*ErrLimitExceeded); !ok {
+		t.Errorf("Pay(): captured payment must count in daily limit, returned = %v", err)
+	}
+}
+
+func TestService_Void_success(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 100_00)
+
+	payment, err := svc.Authorize(account.ID, 80_00, "hotel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Void(payment.ID)
+	if err != nil {
+		t.Fatalf("Void(): error = %v", err)
+	}
+	if account.Balance != 100_00 || account.Hold != 0 || payment.Status != types.PaymentStatusVoid {
+		t.Errorf("Void(): wrong state, account = %+v, payment = %+v", account, payment)
+	}
+	if svc.SumPayments(1) != 0 {
+		t.Errorf("SumPayments(): voided payment must not be counted")
+	}
+	//по снятому резерву ничего не списано - вернуть нечего
+	err = svc.Reject(payment.ID)
+	if err != ErrPaymentNotRefundable || account.Balance != 100_00 {
+		t.Errorf("Reject(): voided payment must return ErrPaymentNotRefundable, returned = %v, balance = %v", err, account.Balance)
+	}
+
+	other, err := svc.Authorize(account.ID, 10_00, "hotel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Reject(other.ID)
+	if err != nil || account.Balance != 100_00 || account.Hold != 0 {
+		t.Errorf("Reject(): must release hold, error = %v, account = %+v", err, account)
+	}
+}
+
+func TestService_Authorize_expires(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 100_00)
+	svc.SetHoldTTL(time.Hour)
+
+	payment, err := svc.Authorize(account.ID, 80_00, "hotel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(time.Hour)
+
+	_, err = svc.Pay(account.ID, 90_00, "auto")
+	if err != nil {
+		t.Fatalf("Pay(): expired hold must be released, error = %v", err)
+	}
+	if payment.Status != types.PaymentStatusVoid {
+		t.Errorf("Pay(): wrong payment = %+v", payment)
+	}
+	_, err = svc.Capture(payment.ID, 10_00)
+	if err != ErrPaymentNotAuthorized {
+		t.Errorf("Capture(): must return ErrPaymentNotAuthorized, returned = %v", err)
+	}
+}
+
+func TestService_Holds_exportImport(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 100_00)
+	svc.SetHoldTTL(time.Hour)
+	payment, err := svc.Authorize(account.ID, 80_00, "hotel")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	imported.SetClock(clock.Now)
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := imported.FindAccountByID(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hold != 80_00 {
+		t.Errorf("Import(): wrong account = %+v", got)
+	}
+
+	clock.Add(2 * time.Hour)
+	err = imported.Void(payment.ID)
+	if err != ErrPaymentNotAuthorized || got.Hold != 0 {
+		t.Errorf("Void(): imported hold must expire, error = %v, account = %+v", err, got)
+	}
+}
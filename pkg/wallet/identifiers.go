@@ -0,0 +1,161 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+var ErrInvalidEmail = errors.New("invalid email")
+var ErrInvalidExternalID = errors.New("external id must not contain ';', '|' or ','")
+var ErrEmailRegistered = errors.New("email already registered")
+var ErrExternalIDRegistered = errors.New("external id already registered")
+
+//ChangePhone - меняет номер телефона аккаунта, прежний номер сохраняется в PreviousPhones.
+//Номер можно сменить и у замороженного аккаунта (например, после кражи телефона).
+func (s *Service) ChangePhone(accountID int64, phone types.Phone) error {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return err
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+	if account.Phone == phone {
+		return nil
+	}
+	for _, acc := range s.accounts {
+		if acc.Phone == phone {
+			return ErrPhoneRegistered
+		}
+	}
+
+	now := s.now()
+	//новый слайс, чтобы не менять записи журнала, которые ссылаются на старый
+	previous := make([]types.PhoneChange, len(account.PreviousPhones), len(account.PreviousPhones)+1)
+	copy(previous, account.PreviousPhones)
+	account.PreviousPhones = append(previous, types.PhoneChange{Phone: account.Phone, ChangedAt: now})
+	account.Phone = phone
+	account.UpdatedAt = now
+	s.recordAccount(OperationChangePhone, account)
+	return nil
+}
+
+//SetEmail - задаёт email аккаунта, пустая строка - удаляет его
+func (s *Service) SetEmail(accountID int64, email string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email != "" && !validEmail(email) {
+		return ErrInvalidEmail
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+	if email != "" {
+		found, err := s.FindAccountByEmail(email)
+		if err == nil && found.ID != accountID {
+			return ErrEmailRegistered
+		}
+	}
+
+	account.Email = email
+	account.UpdatedAt = s.now()
+	s.recordAccount(OperationIdentifiers, account)
+	return nil
+}
+
+//SetExternalID - задаёт идентификатор клиента во внешней системе, пустая строка - удаляет его
+func (s *Service) SetExternalID(accountID int64, externalID string) error {
+	externalID = strings.TrimSpace(externalID)
+	if strings.ContainsAny(externalID, ";|,") {
+		return ErrInvalidExternalID
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+	if externalID != "" {
+		found, err := s.FindAccountByExternalID(externalID)
+		if err == nil && found.ID != accountID {
+			return ErrExternalIDRegistered
+		}
+	}
+
+	account.ExternalID = externalID
+	account.UpdatedAt = s.now()
+	s.recordAccount(OperationIdentifiers, account)
+	return nil
+}
+
+//FindAccountByEmail - находит аккаунт по email без учёта регистра
+func (s *Service) FindAccountByEmail(email string) (*types.Account, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, ErrAccountNotFound
+	}
+	for _, account := range s.accounts {
+		if account.Email == email {
+			return account, nil
+		}
+	}
+	return nil, ErrAccountNotFound
+}
+
+//FindAccountByExternalID - находит аккаунт по идентификатору во внешней системе
+func (s *Service) FindAccountByExternalID(externalID string) (*types.Account, error) {
+	externalID = strings.TrimSpace(externalID)
+	if externalID == "" {
+		return nil, ErrAccountNotFound
+	}
+	for _, account := range s.accounts {
+		if account.ExternalID == externalID {
+			return account, nil
+		}
+	}
+	return nil, ErrAccountNotFound
+}
+
+//FindAccountsByPreviousPhone - аккаунты, у которых когда-то был этот номер.
+//Номер мог переходить от одного клиента к другому, поэтому аккаунтов может быть несколько.
+func (s *Service) FindAccountsByPreviousPhone(phone types.Phone) ([]*types.Account, error) {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	accounts := []*types.Account{}
+	for _, account := range s.accounts {
+		for _, change := range account.PreviousPhones {
+			if change.Phone == phone {
+				accounts = append(accounts, account)
+				break
+			}
+		}
+	}
+	return accounts, nil
+}
+
+//validEmail - простая проверка адреса: одна @, непустые части, точка в домене
+//и никаких символов, ломающих формат дампа
+func validEmail(email string) bool {
+	if strings.ContainsAny(email, ";|, ") {
+		return false
+	}
+	at := strings.Index(email, "@")
+	if at < 1 || strings.Count(email, "@") != 1 {
+		return false
+	}
+	domain := email[at+1:]
+	dot := strings.LastIndex(domain, ".")
+	return dot > 0 && dot < len(domain)-1
+}
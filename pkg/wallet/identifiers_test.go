@@ -0,0 +1,143 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func TestService_ChangePhone_success(t *testing.T) {
+	clock := newTestClock()
+	svc := &Service{}
+	svc.SetClock(clock.Now)
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := svc.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.ChangePhone(account.ID, "+992 00 000 0002")
+	if err != ErrPhoneRegistered {
+		t.Errorf("ChangePhone(): must return ErrPhoneRegistered, returned = %v", err)
+	}
+
+	clock.Add(time.Hour)
+	err = svc.ChangePhone(account.ID, "992000000003")
+	if err != nil {
+		t.Fatalf("ChangePhone(): error = %v", err)
+	}
+	want := []types.PhoneChange{{Phone: "+992000000001", ChangedAt: clock.Now()}}
+	if account.Phone != "+992000000003" || !reflect.DeepEqual(want, account.PreviousPhones) {
+		t.Errorf("ChangePhone(): wrong account = %v", account)
+	}
+
+	//освободившийся номер может получить другой клиент
+	err = svc.ChangePhone(other.ID, "+992000000001")
+	if err != nil {
+		t.Fatalf("ChangePhone(): error = %v", err)
+	}
+	got, err := svc.FindAccountByPhone("+992000000001")
+	if err != nil || got.ID != other.ID {
+		t.Errorf("FindAccountByPhone(): wrong account = %v, error = %v", got, err)
+	}
+	accounts, err := svc.FindAccountsByPreviousPhone("+992000000001")
+	if err != nil || len(accounts) != 1 || accounts[0].ID != account.ID {
+		t.Errorf("FindAccountsByPreviousPhone(): wrong accounts = %v, error = %v", accounts, err)
+	}
+}
+
+func TestService_SetEmailAndExternalID(t *testing.T) {
+	svc := &Service{}
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := svc.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.SetEmail(account.ID, " Client@Example.com ")
+	if err != nil {
+		t.Fatalf("SetEmail(): error = %v", err)
+	}
+	err = svc.SetExternalID(account.ID, "CRM-42")
+	if err != nil {
+		t.Fatalf("SetExternalID(): error = %v", err)
+	}
+
+	got, err := svc.FindAccountByEmail("client@EXAMPLE.com")
+	if err != nil || got != account {
+		t.Errorf("FindAccountByEmail(): wrong account = %v, error = %v", got, err)
+	}
+	got, err = svc.FindAccountByExternalID("CRM-42")
+	if err != nil || got != account {
+		t.Errorf("FindAccountByExternalID(): wrong account = %v, error = %v", got, err)
+	}
+
+	err = svc.SetEmail(other.ID, "client@example.com")
+	if err != ErrEmailRegistered {
+		t.Errorf("SetEmail(): must return ErrEmailRegistered, returned = %v", err)
+	}
+	err = svc.SetExternalID(other.ID, "CRM-42")
+	if err != ErrExternalIDRegistered {
+		t.Errorf("SetExternalID(): must return ErrExternalIDRegistered, returned = %v", err)
+	}
+	for _, email := range []string{"client", "@example.com", "a@b", "a@@b.com", "a;b@c.com"} {
+		err = svc.SetEmail(other.ID, email)
+		if err != ErrInvalidEmail {
+			t.Errorf("SetEmail(%q): must return ErrInvalidEmail, returned = %v", email, err)
+		}
+	}
+
+	err = svc.SetEmail(account.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.FindAccountByEmail("client@example.com")
+	if err != ErrAccountNotFound {
+		t.Errorf("FindAccountByEmail(): must return ErrAccountNotFound, returned = %v", err)
+	}
+}
+
+func TestService_Identifiers_exportImport(t *testing.T) {
+	svc := &Service{}
+	svc.SetClock(newTestClock().Now)
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.ChangePhone(account.ID, "+992000000002")
+	svc.ChangePhone(account.ID, "+992000000003")
+	svc.SetEmail(account.ID, "client@example.com")
+	svc.SetExternalID(account.ID, "CRM-42")
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := imported.FindAccountByExternalID("CRM-42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Phone != "+992000000003" || got.Email != "client@example.com" || len(got.PreviousPhones) != 2 {
+		t.Fatalf("Import(): wrong account = %v", got)
+	}
+	for i, change := range account.PreviousPhones {
+		if got.PreviousPhones[i].Phone != change.Phone || !got.PreviousPhones[i].ChangedAt.Equal(change.ChangedAt) {
+			t.Errorf("Import(): want => %v got => %v", change, got.PreviousPhones[i])
+		}
+	}
+}
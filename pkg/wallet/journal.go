@@ -0,0 +1,339 @@
+package wallet
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+//Operation представляет собой тип операции, записанной в журнал
+type Operation string
+
+//Операции, которые попадают в журнал
+const (
+	OperationRegister  Operation = "REGISTER"
+	OperationDeposit   Operation = "DEPOSIT"
+	OperationPay       Operation = "PAY"
+	OperationReject    Operation = "REJECT"
+	OperationFavorite       Operation = "FAVORITE"
+	OperationFavoriteUpdate Operation = "FAVORITE_UPDATE"
+	OperationFavoriteDelete Operation = "FAVORITE_DELETE"
+	OperationOverdraft      Operation = "OVERDRAFT"
+	OperationAuthorize      Operation = "AUTHORIZE"
+	OperationCapture        Operation = "CAPTURE"
+	OperationVoid           Operation = "VOID"
+	OperationRefund         Operation = "REFUND"
+	OperationTransfer       Operation = "TRANSFER"
+	OperationFreeze         Operation = "FREEZE"
+	OperationUnfreeze       Operation = "UNFREEZE"
+	OperationClose          Operation = "CLOSE"
+	OperationChangePhone    Operation = "CHANGE_PHONE"
+	OperationIdentifiers    Operation = "IDENTIFIERS"
+	OperationProfile        Operation = "PROFILE"
+	OperationUpgradeTier    Operation = "UPGRADE_TIER"
+	OperationComplete       Operation = "COMPLETE"
+	OperationCashback       Operation = "CASHBACK"
+	OperationClawback       Operation = "CLAWBACK"
+)
+
+//JournalEntry - запись журнала: состояние одной записи (аккаунта, платежа, избранного или пополнения)
+//сразу после операции. Заполнено ровно одно из полей Account, Payment, Favorite, Deposit.
+//Для OperationFavoriteDelete это последнее состояние удалённого избранного.
+type JournalEntry struct {
+	Time      time.Time
+	Operation Operation
+	Account   *types.Account
+	Payment   *types.Payment
+	Favorite  *types.Favorite
+	Deposit   *types.Deposit
+}
+
+const (
+	journalFile  = "journal.dump"
+	snapshotsDir = "snapshots"
+)
+
+//recordAccount - записывает в журнал состояние аккаунта после операции
+func (s *Service) recordAccount(operation Operation, account *types.Account) {
+	copyAccount := *account
+	s.journal = append(s.journal, JournalEntry{Time: s.now(), Operation: operation, Account: &copyAccount})
+}
+
+//recordPayment - записывает в журнал состояние платежа после операции
+func (s *Service) recordPayment(operation Operation, payment *types.Payment) {
+	copyPayment := *payment
+	s.journal = append(s.journal, JournalEntry{Time: s.now(), Operation: operation, Payment: &copyPayment})
+}
+
+//recordFavorite - записывает в журнал состояние избранного после операции
+func (s *Service) recordFavorite(operation Operation, favorite *types.Favorite) {
+	copyFavorite := *favorite
+	s.journal = append(s.journal, JournalEntry{Time: s.now(), Operation: operation, Favorite: &copyFavorite})
+}
+
+//recordDeposit - записывает в журнал пополнение
+func (s *Service) recordDeposit(operation Operation, deposit *types.Deposit) {
+	copyDeposit := *deposit
+	s.journal = append(s.journal, JournalEntry{Time: s.now(), Operation: operation, Deposit: &copyDeposit})
+}
+
+//Journal - возвращает записи журнала, сделанные этим сервисом
+func (s *Service) Journal() []JournalEntry {
+	journal := make([]JournalEntry, len(s.journal))
+	copy(journal, s.journal)
+	return journal
+}
+
+//encodeJournalEntry - сериализует запись журнала: время;операция;вид записи;поля записи
+func encodeJournalEntry(entry JournalEntry) string {
+	prefix := strconv.FormatInt(entry.Time.UnixNano(), 10) + ";" + string(entry.Operation) + ";"
+	switch {
+	case entry.Account != nil:
+		return prefix + "account;" + encodeAccount(entry.Account)
+	case entry.Payment != nil:
+		return prefix + "payment;" + encodePayment(entry.Payment)
+	case entry.Deposit != nil:
+		return prefix + "deposit;" + encodeDeposit(entry.Deposit)
+	default:
+		return prefix + "favorite;" + encodeFavorite(entry.Favorite)
+	}
+}
+
+//decodeJournalEntry - восстанавливает запись журнала из строки
+func decodeJournalEntry(line string) (JournalEntry, error) {
+	value := strings.Split(line, ";")
+	if len(value) < 4 {
+		return JournalEntry{}, ErrInvalidDump
+	}
+
+	nanos, err := strconv.ParseInt(value[0], 10, 64)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	entry := JournalEntry{Time: time.Unix(0, nanos), Operation: Operation(value[1])}
+
+	switch value[2] {
+	case "account":
+		entry.Account, err = decodeAccount(value[3:])
+	case "payment":
+		entry.Payment, err = decodePayment(value[3:])
+	case "favorite":
+		entry.Favorite, err = decodeFavorite(value[3:])
+	case "deposit":
+		entry.Deposit, err = decodeDeposit(value[3:])
+	default:
+		err = ErrInvalidDump
+	}
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	return entry, nil
+}
+
+//FlushJournal - дописывает в dir/journal.dump записи журнала, которые ещё не были сохранены.
+//Export журнал не сохраняет, поэтому после операций, которые нужно уметь восстановить
+//через RestoreAt, журнал сохраняется этим методом или Snapshot.
+func (s *Service) FlushJournal(dir string) error {
+	if s.journalFlushed == len(s.journal) {
+		return nil
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		log.Print(err)
+		return ErrFileNotFound
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil {
+			log.Print(cerr)
+		}
+	}()
+
+	data := ""
+	for _, entry := range s.journal[s.journalFlushed:] {
+		data += encodeJournalEntry(entry) + "|"
+	}
+	_, err = file.Write([]byte(data))
+	if err != nil {
+		log.Print(err)
+		return ErrFileNotFound
+	}
+
+	s.journalFlushed = len(s.journal)
+	return nil
+}
+
+//readJournal - читает все записи журнала из dir/journal.dump
+func readJournal(dir string) ([]JournalEntry, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, journalFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrFileNotFound
+	}
+
+	lines := strings.Split(string(content), "|")
+	lines = lines[:len(lines)-1]
+
+	entries := make([]JournalEntry, 0, len(lines))
+	for _, line := range lines {
+		entry, err := decodeJournalEntry(line)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+//Snapshot - сохраняет журнал в dir/journal.dump и полное состояние сервиса
+//в dir/snapshots/<время в наносекундах>. Возвращает время снимка.
+func (s *Service) Snapshot(dir string) (time.Time, error) {
+	err := s.FlushJournal(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	at := s.now()
+	snapshotDir := filepath.Join(dir, snapshotsDir, strconv.FormatInt(at.UnixNano(), 10))
+	err = os.MkdirAll(snapshotDir, 0777)
+	if err != nil {
+		log.Print(err)
+		return time.Time{}, ErrFileNotFound
+	}
+
+	err = s.Export(snapshotDir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return at, nil
+}
+
+//latestSnapshot - находит самый поздний снимок в dir, сделанный не позже at
+func latestSnapshot(dir string, at time.Time) (string, time.Time, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(dir, snapshotsDir))
+	if os.IsNotExist(err) {
+		return "", time.Time{}, ErrSnapshotNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return "", time.Time{}, ErrFileNotFound
+	}
+
+	times := []int64{}
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		nanos, err := strconv.ParseInt(info.Name(), 10, 64)
+		if err != nil || nanos > at.UnixNano() {
+			continue
+		}
+		times = append(times, nanos)
+	}
+	if len(times) == 0 {
+		return "", time.Time{}, ErrSnapshotNotFound
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	latest := times[len(times)-1]
+	return filepath.Join(dir, snapshotsDir, strconv.FormatInt(latest, 10)), time.Unix(0, latest), nil
+}
+
+//RestoreAt - восстанавливает состояние сервиса на момент at: загружает последний снимок
+//до этого времени и проигрывает записи журнала вплоть до at. Возвращает новый сервис,
+//с которым можно работать не затрагивая текущее состояние.
+//Если снимка не позже at нет, возвращается ErrSnapshotNotFound.
+func RestoreAt(dir string, at time.Time) (*Service, error) {
+	snapshotDir, from, err := latestSnapshot(dir, at)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := &Service{}
+	err = svc.Import(snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := readJournal(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		//записи хранят состояние после операции, поэтому повторное применение
+		//записей, попавших в снимок, ничего не меняет
+		if entry.Time.Before(from) || entry.Time.After(at) {
+			continue
+		}
+		svc.apply(entry)
+	}
+	return svc, nil
+}
+
+//apply - применяет запись журнала к состоянию сервиса
+func (s *Service) apply(entry JournalEntry) {
+	switch {
+	case entry.Account != nil:
+		account := *entry.Account
+		if account.ID > s.nextAccountID {
+			s.nextAccountID = account.ID
+		}
+		for _, acc := range s.accounts {
+			if acc.ID == account.ID {
+				*acc = account
+				return
+			}
+		}
+		s.accounts = append(s.accounts, &account)
+	case entry.Payment != nil:
+		payment := *entry.Payment
+		for _, pay := range s.payments {
+			if pay.ID == payment.ID {
+				*pay = payment
+				return
+			}
+		}
+		s.payments = append(s.payments, &payment)
+		if payment.Status == types.PaymentStatusAuthorized {
+			s.holds = append(s.holds, &payment)
+		}
+	case entry.Deposit != nil:
+		deposit := *entry.Deposit
+		for _, saved := range s.deposits {
+			if saved.ID == deposit.ID {
+				return
+			}
+		}
+		s.deposits = append(s.deposits, &deposit)
+	case entry.Favorite != nil:
+		favorite := *entry.Favorite
+		for i, fav := range s.favorites {
+			if fav.ID != favorite.ID {
+				continue
+			}
+			if entry.Operation == OperationFavoriteDelete {
+				s.favorites = append(s.favorites[:i], s.favorites[i+1:]...)
+				s.Unschedule(favorite.ID)
+				return
+			}
+			*fav = favorite
+			return
+		}
+		if entry.Operation != OperationFavoriteDelete {
+			s.favorites = append(s.favorites, &favorite)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestService_RestoreAt_success(t *testing.T) {
+	dir := t.TempDir()
+	clock := newTestClock()
+	svc := &Service{}
+	svc.SetClock(clock.Now)
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Deposit(account.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Snapshot(dir)
+	if err != nil {
+		t.Fatalf("Snapshot(): error = %v", err)
+	}
+
+	clock.Add(time.Hour)
+	payment, err := svc.Pay(account.ID, 30_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(time.Hour)
+	at := clock.Now()
+	clock.Add(time.Hour)
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Snapshot(dir)
+	if err != nil {
+		t.Fatalf("Snapshot(): error = %v", err)
+	}
+
+	restored, err := RestoreAt(dir, at)
+	if err != nil {
+		t.Fatalf("RestoreAt(): error = %v", err)
+	}
+
+	got, err := restored.FindAccountByID(account.ID)
+	if err != nil {
+		t.Fatalf("FindAccountByID(): error = %v", err)
+	}
+	if got.Balance != 70_00 {
+		t.Errorf("RestoreAt(): wrong balance, want => %v got => %v", 70_00, got.Balance)
+	}
+
+	history, err := restored.ExportAccountHistory(account.ID)
+	if err != nil {
+		t.Fatalf("ExportAccountHistory(): error = %v", err)
+	}
+	if len(history) != 1 || history[0].Status != "INPROGRESS" {
+		t.Errorf("RestoreAt(): wrong history = %v", history)
+	}
+
+	live, _ := svc.FindAccountByID(account.ID)
+	if live.Balance != 100_00 {
+		t.Errorf("RestoreAt(): live state changed, balance = %v", live.Balance)
+	}
+}
+
+func TestService_RestoreAt_beforeSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	clock := newTestClock()
+	svc := &Service{}
+	svc.SetClock(clock.Now)
+
+	at := clock.Now()
+	clock.Add(time.Minute)
+	_, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Snapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = RestoreAt(dir, at)
+	if err != ErrSnapshotNotFound {
+		t.Errorf("RestoreAt(): must return ErrSnapshotNotFound, returned = %v", err)
+	}
+}
+
+func TestService_FlushJournal_success(t *testing.T) {
+	dir := t.TempDir()
+	clock := newTestClock()
+	svc := &Service{}
+	svc.SetClock(clock.Now)
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Snapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Add(time.Minute)
+	err = svc.Deposit(account.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.FlushJournal(dir)
+	if err != nil {
+		t.Fatalf("FlushJournal(): error = %v", err)
+	}
+	//повторный вызов без новых записей ничего не дописывает
+	err = svc.FlushJournal(dir)
+	if err != nil {
+		t.Fatalf("FlushJournal(): error = %v", err)
+	}
+
+	restored, err := RestoreAt(dir, clock.Now())
+	if err != nil {
+		t.Fatalf("RestoreAt(): error = %v", err)
+	}
+	got, err := restored.FindAccountByID(account.ID)
+	if err != nil {
+		t.Fatalf("FindAccountByID(): error = %v", err)
+	}
+	if got.Balance != 100_00 || len(restored.FindDeposits(account.ID)) != 1 {
+		t.Errorf("RestoreAt(): wrong balance = %v, deposits = %v", got.Balance, restored.FindDeposits(account.ID))
+	}
+}
@@ -0,0 +1,235 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrAccountFrozen = errors.New("account is frozen")
+var ErrAccountClosed = errors.New("account is closed")
+var ErrAccountNotFrozen = errors.New("account is not frozen")
+var ErrAccountNotEmpty = errors.New("account has balance or pending holds")
+var ErrReasonRequired = errors.New("reason is required")
+var ErrSameAccount = errors.New("source and target accounts are the same")
+
+//TransferCategory - категория платежей-переводов между счетами
+const TransferCategory types.PaymentCategory = "transfer"
+
+//checkActive - операции по счёту разрешены только в состоянии ACTIVE
+func checkActive(account *types.Account) error {
+	switch account.Status {
+	case types.AccountStatusFrozen:
+		return ErrAccountFrozen
+	case types.AccountStatusClosed:
+		return ErrAccountClosed
+	}
+	return nil
+}
+
+//checkReason - причина смены состояния обязательна и не должна ломать формат дампа
+func checkReason(reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return ErrReasonRequired
+	}
+	if strings.ContainsAny(reason, ";|") {
+		return ErrInvalidReason
+	}
+	return nil
+}
+
+//Freeze - замораживает счёт: платежи, пополнения и переводы запрещены до Unfreeze.
+//Отмена и возврат уже сделанных платежей остаются возможны.
+func (s *Service) Freeze(accountID int64, reason string) error {
+	err := checkReason(reason)
+	if err != nil {
+		return err
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	err = checkActive(account)
+	if err != nil {
+		return err
+	}
+
+	s.setStatus(account, types.AccountStatusFrozen, reason, OperationFreeze)
+	return nil
+}
+
+//Unfreeze - размораживает счёт
+func (s *Service) Unfreeze(accountID int64, reason string) error {
+	err := checkReason(reason)
+	if err != nil {
+		return err
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if account.Status != types.AccountStatusFrozen {
+		return ErrAccountNotFrozen
+	}
+
+	s.setStatus(account, types.AccountStatusActive, reason, OperationUnfreeze)
+	return nil
+}
+
+//Close - закрывает счёт с нулевым балансом и без резервов. Закрыть можно и замороженный счёт.
+//Закрытый счёт остаётся в экспорте и истории, но никакие операции по нему больше невозможны.
+func (s *Service) Close(accountID int64, reason string) error {
+	err := checkReason(reason)
+	if err != nil {
+		return err
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+	s.expireHolds()
+	if account.Balance != 0 || account.Hold != 0 {
+		return ErrAccountNotEmpty
+	}
+
+	s.close(account, reason)
+	return nil
+}
+
+//CloseWithPayout - переводит остаток на счёт payoutAccountID и закрывает счёт.
+//Выплата делается и с замороженного счёта, ограничения расходов и комиссии на неё не действуют.
+func (s *Service) CloseWithPayout(accountID int64, reason string, payoutAccountID int64) (*types.Payment, error) {
+	err := checkReason(reason)
+	if err != nil {
+		return nil, err
+	}
+	if accountID == payoutAccountID {
+		return nil, ErrSameAccount
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Status == types.AccountStatusClosed {
+		return nil, ErrAccountClosed
+	}
+	payoutAccount, err := s.FindAccountByID(payoutAccountID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkActive(payoutAccount)
+	if err != nil {
+		return nil, err
+	}
+	s.expireHolds()
+	if account.Balance < 0 || account.Hold != 0 {
+		return nil, ErrAccountNotEmpty
+	}
+
+	var payout *types.Payment
+	if account.Balance > 0 {
+		payout = s.transfer(account, payoutAccount, account.Balance, 0)
+	}
+	s.close(account, reason)
+	return payout, nil
+}
+
+//Transfer - переводит amount между счетами. Перевод проводится сразу (статус OK),
+//отменить или вернуть его нельзя - нужен обратный перевод. Кешбэк за переводы не начисляется.
+func (s *Service) Transfer(fromAccountID int64, toAccountID int64, amount types.Money) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	if fromAccountID == toAccountID {
+		return nil, ErrSameAccount
+	}
+	from, err := s.FindAccountByID(fromAccountID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.FindAccountByID(toAccountID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkActive(from)
+	if err != nil {
+		return nil, err
+	}
+	err = checkActive(to)
+	if err != nil {
+		return nil, err
+	}
+
+	s.expireHolds()
+	fee := s.fee(from, amount, TransferCategory)
+	if from.Available()+from.Overdraft < amount+fee {
+		return nil, ErrNotEnoughBalance
+	}
+	err = s.checkLimits(fromAccountID, amount, TransferCategory)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkTier(from, 0, amount+fee)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkTier(to, amount, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.transfer(from, to, amount, fee), nil
+}
+
+//transfer - списывает amount и комиссию fee с from и зачисляет amount на to без проверок
+func (s *Service) transfer(from *types.Account, to *types.Account, amount types.Money, fee types.Money) *types.Payment {
+	now := s.now()
+	from.Balance -= amount + fee
+	from.UpdatedAt = now
+	s.updateOverdraft(from)
+	s.addTurnover(from, amount+fee)
+	to.Balance += amount
+	to.UpdatedAt = now
+	s.updateOverdraft(to)
+	s.addTurnover(to, amount)
+
+	payment := &types.Payment{
+		ID:          uuid.New().String(),
+		AccountID:   from.ID,
+		Amount:      amount,
+		Category:    TransferCategory,
+		Status:      types.PaymentStatusOk,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ToAccountID: to.ID,
+	}
+	s.payments = append(s.payments, payment)
+	s.recordAccount(OperationTransfer, from)
+	s.recordAccount(OperationTransfer, to)
+	s.recordPayment(OperationTransfer, payment)
+	s.chargeFee(payment, fee, OperationTransfer)
+	return payment
+}
+
+//close - закрывает счёт и отменяет расписания его избранного, которые уже не смогут выполниться
+func (s *Service) close(account *types.Account, reason string) {
+	for _, favorite := range s.favorites {
+		if favorite.AccountID == account.ID {
+			s.Unschedule(favorite.ID)
+		}
+	}
+	s.setStatus(account, types.AccountStatusClosed, reason, OperationClose)
+}
+
+//setStatus - меняет состояние счёта и записывает это в журнал
+func (s *Service) setStatus(account *types.Account, status types.AccountStatus, reason string, operation Operation) {
+	account.Status = status
+	account.StatusReason = reason
+	account.UpdatedAt = s.now()
+	s.recordAccount(operation, account)
+}
@@ -0,0 +1,312 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func TestService_Freeze_blocksOperations(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	other := svc.addTestAccount(t, "+992000000002", 0)
+	payment, err := svc.Pay(account.ID, 100_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	favorite, err := svc.FavoritePayment(payment.ID, "Auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Freeze(account.ID, "stolen phone")
+	if err != nil {
+		t.Fatalf("Freeze(): error = %v", err)
+	}
+	if account.Status != types.AccountStatusFrozen || account.StatusReason != "stolen phone" {
+		t.Errorf("Freeze(): wrong account = %v", account)
+	}
+
+	_, err = svc.Pay(account.ID, 10_00, "auto")
+	if err != ErrAccountFrozen {
+		t.Errorf("Pay(): must return ErrAccountFrozen, returned = %v", err)
+	}
+	err = svc.Deposit(account.ID, 10_00)
+	if err != ErrAccountFrozen {
+		t.Errorf("Deposit(): must return ErrAccountFrozen, returned = %v", err)
+	}
+	_, err = svc.Repeat(payment.ID)
+	if err != ErrAccountFrozen {
+		t.Errorf("Repeat(): must return ErrAccountFrozen, returned = %v", err)
+	}
+	_, err = svc.PayFromFavorite(favorite.ID)
+	if err != ErrAccountFrozen {
+		t.Errorf("PayFromFavorite(): must return ErrAccountFrozen, returned = %v", err)
+	}
+	_, err = svc.Transfer(account.ID, other.ID, 10_00)
+	if err != ErrAccountFrozen {
+		t.Errorf("Transfer(): must return ErrAccountFrozen, returned = %v", err)
+	}
+	_, err = svc.Transfer(other.ID, account.ID, 10_00)
+	if err != ErrAccountFrozen {
+		t.Errorf("Transfer(): to frozen account must return ErrAccountFrozen, returned = %v", err)
+	}
+	_, err = svc.Authorize(account.ID, 10_00, "auto")
+	if err != ErrAccountFrozen {
+		t.Errorf("Authorize(): must return ErrAccountFrozen, returned = %v", err)
+	}
+
+	//деньги по спорному платежу вернуть можно
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Errorf("Reject(): error = %v", err)
+	}
+
+	err = svc.Unfreeze(account.ID, "phone recovered")
+	if err != nil {
+		t.Fatalf("Unfreeze(): error = %v", err)
+	}
+	_, err = svc.Pay(account.ID, 10_00, "auto")
+	if err != nil {
+		t.Errorf("Pay(): after unfreeze error = %v", err)
+	}
+	err = svc.Unfreeze(account.ID, "again")
+	if err != ErrAccountNotFrozen {
+		t.Errorf("Unfreeze(): must return ErrAccountNotFrozen, returned = %v", err)
+	}
+}
+
+func TestService_Close_requiresZeroBalance(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	other := svc.addTestAccount(t, "+992000000002", 0)
+
+	err := svc.Close(account.ID, "customer request")
+	if err != ErrAccountNotEmpty {
+		t.Errorf("Close(): must return ErrAccountNotEmpty, returned = %v", err)
+	}
+	err = svc.Close(account.ID, " ")
+	if err != ErrReasonRequired {
+		t.Errorf("Close(): must return ErrReasonRequired, returned = %v", err)
+	}
+
+	err = svc.Close(other.ID, "customer request")
+	if err != nil {
+		t.Fatalf("Close(): error = %v", err)
+	}
+	err = svc.Deposit(other.ID, 10_00)
+	if err != ErrAccountClosed {
+		t.Errorf("Deposit(): must return ErrAccountClosed, returned = %v", err)
+	}
+	err = svc.Freeze(other.ID, "fraud")
+	if err != ErrAccountClosed {
+		t.Errorf("Freeze(): must return ErrAccountClosed, returned = %v", err)
+	}
+	err = svc.Close(other.ID, "customer request")
+	if err != ErrAccountClosed {
+		t.Errorf("Close(): must return ErrAccountClosed, returned = %v", err)
+	}
+}
+
+func TestService_CloseWithPayout_success(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	other := svc.addTestAccount(t, "+992000000002", 0)
+	payment, err := svc.Pay(account.ID, 100_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	favorite, err := svc.FavoritePayment(payment.ID, "Auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.ScheduleFavorite(favorite.ID, "daily 09:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Freeze(account.ID, "deceased")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payout, err := svc.CloseWithPayout(account.ID, "deceased", other.ID)
+	if err != nil {
+		t.Fatalf("CloseWithPayout(): error = %v", err)
+	}
+	if payout.Amount != 900_00 || payout.ToAccountID != other.ID || payout.Status != types.PaymentStatusOk {
+		t.Errorf("CloseWithPayout(): wrong payout = %v", payout)
+	}
+	if account.Balance != 0 || other.Balance != 900_00 || account.Status != types.AccountStatusClosed {
+		t.Errorf("CloseWithPayout(): wrong accounts = %v, %v", account, other)
+	}
+	_, err = svc.FindSchedule(favorite.ID)
+	if err != ErrScheduleNotFound {
+		t.Errorf("CloseWithPayout(): schedules must be removed, returned = %v", err)
+	}
+	err = svc.Reject(payment.ID)
+	if err != ErrAccountClosed {
+		t.Errorf("Reject(): must return ErrAccountClosed, returned = %v", err)
+	}
+}
+
+func TestService_Transfer_success(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	other := svc.addTestAccount(t, "+992000000002", 0)
+
+	payment, err := svc.Transfer(account.ID, other.ID, 300_00)
+	if err != nil {
+		t.Fatalf("Transfer(): error = %v", err)
+	}
+	if account.Balance != 700_00 || other.Balance != 300_00 {
+		t.Errorf("Transfer(): wrong balances = %v, %v", account.Balance, other.Balance)
+	}
+	if payment.Category != TransferCategory || payment.ToAccountID != other.ID {
+		t.Errorf("Transfer(): wrong payment = %v", payment)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != ErrPaymentNotRefundable {
+		t.Errorf("Reject(): must return ErrPaymentNotRefundable, returned = %v", err)
+	}
+	_, err = svc.Transfer(account.ID, other.ID, 800_00)
+	if err != ErrNotEnoughBalance {
+		t.Errorf("Transfer(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	_, err = svc.Transfer(account.ID, account.ID, 1_00)
+	if err != ErrSameAccount {
+		t.Errorf("Transfer(): must return ErrSameAccount, returned = %v", err)
+	}
+}
+
+func TestService_Transfer_noCashback(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	other := svc.addTestAccount(t, "+992000000002", 0)
+	err := svc.SetCashbackRule(types.CashbackRule{Category: TransferCategory, Percent: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.Transfer(account.ID, other.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.FindCashback(payment.ID)
+	if err != ErrPaymentNotFound {
+		t.Errorf("Transfer(): cashback must not be accrued, returned = %v", err)
+	}
+	if account.Balance != 900_00 || other.Balance != 100_00 {
+		t.Errorf("Transfer(): wrong balances = %v, %v", account.Balance, other.Balance)
+	}
+}
+
+func TestService_Close_exportImport(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	other := svc.addTestAccount(t, "+992000000002", 0)
+	_, err := svc.Transfer(account.ID, other.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Close(account.ID, "customer request")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := imported.FindAccountByID(account.ID)
+	if err != nil {
+		t.Fatalf("Import(): closed account must be exported, error = %v", err)
+	}
+	if got.Status != types.AccountStatusClosed || got.StatusReason != "customer request" {
+		t.Errorf("Import(): wrong account = %v", got)
+	}
+	history, err := imported.ExportAccountHistory(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].ToAccountID != other.ID {
+		t.Errorf("Import(): wrong history = %v", history)
+	}
+}
+
+func TestService_Repeat_notRepeatable(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	other := svc.addTestAccount(t, "+992000000002", 0)
+	svc.SetFeeRule(types.FeeRule{Category: "auto", Fixed: 1_00})
+	svc.SetCashbackRule(types.CashbackRule{Category: "auto", Percent: 100})
+
+	transfer, err := svc.Transfer(account.ID, other.ID, 10_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := svc.Pay(account.ID, 100_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refund, err := svc.Refund(payment.ID, 10_00, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee, err := svc.FindFee(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Complete(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cashback, err := svc.FindCashback(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hold, err := svc.Authorize(account.ID, 10_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balance := account.Balance
+	tests := []struct {
+		name string
+		id   string
+	}{
+		{"transfer", transfer.ID},
+		{"refund", refund.ID},
+		{"fee", fee.ID},
+		{"clawback", cashback.ID},
+		{"authorized", hold.ID},
+	}
+	for _, test := range tests {
+		_, err := svc.Repeat(test.id)
+		if err != ErrPaymentNotRepeatable {
+			t.Errorf("Repeat(%v): must return ErrPaymentNotRepeatable, returned = %v", test.name, err)
+		}
+	}
+	if account.Balance != balance {
+		t.Errorf("Repeat(): rejected repeats must not change balance = %v", account.Balance)
+	}
+
+	//кешбэк, который ещё не отменён, тоже не повторяется
+	payment, err = svc.Pay(account.ID, 100_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Complete(payment.ID)
+	cashback, err = svc.FindCashback(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Repeat(cashback.ID)
+	if err != ErrPaymentNotRepeatable {
+		t.Errorf("Repeat(cashback): must return ErrPaymentNotRepeatable, returned = %v", err)
+	}
+}
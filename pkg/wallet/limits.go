@@ -0,0 +1,227 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+//LimitKind представляет собой вид ограничения расходов
+type LimitKind string
+
+//Виды ограничений расходов
+const (
+	LimitMaxPayment LimitKind = "max payment"
+	LimitDaily      LimitKind = "daily"
+	LimitMonthly    LimitKind = "monthly"
+	LimitCategory   LimitKind = "category monthly"
+)
+
+//ErrLimitExceeded - платёж превышает одно из ограничений расходов аккаунта
+type ErrLimitExceeded struct {
+	Kind     LimitKind
+	Category types.PaymentCategory //Заполнено для LimitCategory
+	Limit    types.Money
+	Spent    types.Money //Сколько уже потрачено за период
+	Amount   types.Money //Сумма отклонённого платежа
+}
+
+func (e *ErrLimitExceeded) Error() string {
+	if e.Kind == LimitCategory {
+		return fmt.Sprintf("%s limit for %s exceeded: limit %d, spent %d, amount %d", e.Kind, e.Category, e.Limit, e.Spent, e.Amount)
+	}
+	return fmt.Sprintf("%s limit exceeded: limit %d, spent %d, amount %d", e.Kind, e.Limit, e.Spent, e.Amount)
+}
+
+//SetLimits - задаёт ограничения расходов аккаунта, заменяя предыдущие.
+//Категории приводятся к кодам из справочника; если несколько синонимов одной категории
+//заданы с разными лимитами, действует меньший.
+func (s *Service) SetLimits(limits types.Limits) error {
+	if limits.MaxPayment < 0 || limits.Daily < 0 || limits.Monthly < 0 {
+		return ErrAmountMustBePositive
+	}
+	for _, limit := range limits.Categories {
+		if limit < 0 {
+			return ErrAmountMustBePositive
+		}
+	}
+	_, err := s.FindAccountByID(limits.AccountID)
+	if err != nil {
+		return err
+	}
+
+	index := s.categoryIndex()
+	categories := map[types.PaymentCategory]types.Money{}
+	for category, limit := range limits.Categories {
+		category = canonicalCategory(index, category)
+		saved, ok := categories[category]
+		if !ok || limit < saved {
+			categories[category] = limit
+		}
+	}
+	limits.Categories = categories
+
+	for i, saved := range s.limits {
+		if saved.AccountID == limits.AccountID {
+			s.limits[i] = &limits
+			return nil
+		}
+	}
+	s.limits = append(s.limits, &limits)
+	return nil
+}
+
+//FindLimits - ограничения расходов аккаунта; если они не заданы - нулевое значение (без ограничений)
+func (s *Service) FindLimits(accountID int64) types.Limits {
+	for _, limits := range s.limits {
+		if limits.AccountID == accountID {
+			return *limits
+		}
+	}
+	return types.Limits{AccountID: accountID}
+}
+
+//checkLimits - проверяет, что новый платёж не превысит ограничения аккаунта
+func (s *Service) checkLimits(accountID int64, amount types.Money, category types.PaymentCategory) error {
+	limits := s.FindLimits(accountID)
+	if limits.MaxPayment == 0 && limits.Daily == 0 && limits.Monthly == 0 && len(limits.Categories) == 0 {
+		return nil
+	}
+
+	if limits.MaxPayment != 0 && amount > limits.MaxPayment {
+		return &ErrLimitExceeded{Kind: LimitMaxPayment, Limit: limits.MaxPayment, Amount: amount}
+	}
+
+	now := s.now()
+	year, month, day := now.Date()
+	dayStart := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+
+	index := s.categoryIndex()
+	refunds := SpentOptions.refundTotals(s.payments)
+	daily, monthly, categoryMonthly := types.Money(0), types.Money(0), types.Money(0)
+	for _, payment := range s.payments {
+		if payment.AccountID != accountID || payment.CreatedAt.Before(monthStart) {
+			continue
+		}
+		//резервы тоже учитываются, иначе лимит можно обойти через Authorize
+		if !SpentOptions.accepts(payment) && payment.Status != types.PaymentStatusAuthorized {
+			continue
+		}
+		spent := SpentOptions.amount(payment, refunds)
+		monthly += spent
+		if sameCategory(index, payment.Category, category) {
+			categoryMonthly += spent
+		}
+		if !payment.CreatedAt.Before(dayStart) {
+			daily += spent
+		}
+	}
+
+	if limits.Daily != 0 && daily+amount > limits.Daily {
+		return &ErrLimitExceeded{Kind: LimitDaily, Limit: limits.Daily, Spent: daily, Amount: amount}
+	}
+	if limits.Monthly != 0 && monthly+amount > limits.Monthly {
+		return &ErrLimitExceeded{Kind: LimitMonthly, Limit: limits.Monthly, Spent: monthly, Amount: amount}
+	}
+	limit, ok := categoryLimit(index, limits, category)
+	if ok && limit != 0 && categoryMonthly+amount > limit {
+		return &ErrLimitExceeded{Kind: LimitCategory, Category: category, Limit: limit, Spent: categoryMonthly, Amount: amount}
+	}
+	return nil
+}
+
+//categoryLimit - месячный лимит категории. Лимиты могли быть загружены раньше справочника,
+//поэтому ключи сравниваются по коду категории; из нескольких подходящих действует меньший.
+func categoryLimit(index map[types.PaymentCategory]*types.Category, limits types.Limits, category types.PaymentCategory) (types.Money, bool) {
+	limit, found := types.Money(0), false
+	for key, value := range limits.Categories {
+		if sameCategory(index, key, category) && (!found || value < limit) {
+			limit, found = value, true
+		}
+	}
+	return limit, found
+}
+
+//encodeLimits - сериализует ограничения: аккаунт;платёж;день;месяц;категория=сумма,...
+func encodeLimits(limits *types.Limits) string {
+	categories := []string{}
+	for category, limit := range limits.Categories {
+		categories = append(categories, string(category)+"="+strconv.FormatInt(int64(limit), 10))
+	}
+	sort.Strings(categories)
+
+	return strconv.FormatInt(limits.AccountID, 10) + ";" +
+		strconv.FormatInt(int64(limits.MaxPayment), 10) + ";" +
+		strconv.FormatInt(int64(limits.Daily), 10) + ";" +
+		strconv.FormatInt(int64(limits.Monthly), 10) + ";" +
+		strings.Join(categories, ",")
+}
+
+//decodeLimits - восстанавливает ограничения из полей строки дампа
+func decodeLimits(value []string) (*types.Limits, error) {
+	if len(value) < 5 {
+		return nil, ErrInvalidDump
+	}
+
+	numbers := make([]int64, 4)
+	for i := range numbers {
+		number, err := strconv.ParseInt(value[i], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		numbers[i] = number
+	}
+
+	limits := &types.Limits{
+		AccountID:  numbers[0],
+		MaxPayment: types.Money(numbers[1]),
+		Daily:      types.Money(numbers[2]),
+		Monthly:    types.Money(numbers[3]),
+		Categories: map[types.PaymentCategory]types.Money{},
+	}
+	if value[4] == "" {
+		return limits, nil
+	}
+	for _, pair := range strings.Split(value[4], ",") {
+		separator := strings.LastIndex(pair, "=")
+		if separator == -1 {
+			return nil, ErrInvalidDump
+		}
+		limit, err := strconv.ParseInt(pair[separator+1:], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		limits.Categories[types.PaymentCategory(pair[:separator])] = types.Money(limit)
+	}
+	return limits, nil
+}
+
+//exportLimits - сохраняет ограничения расходов в dir/limits.dump
+func (s *Service) exportLimits(dir string) error {
+	records := make([]string, 0, len(s.limits))
+	for _, limits := range s.limits {
+		records = append(records, encodeLimits(limits))
+	}
+	return writeDump(dir, "limits.dump", records)
+}
+
+//importLimits - загружает ограничения расходов из dir/limits.dump
+func (s *Service) importLimits(dir string) error {
+	values, err := readDump(dir, "limits.dump")
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		limits, err := decodeLimits(value)
+		if err != nil {
+			return err
+		}
+		s.limits = append(s.limits, limits)
+	}
+	return nil
+}
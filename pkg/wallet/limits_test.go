@@ -0,0 +1,142 @@
+package wallet
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func checkLimitExceeded(t *testing.T, err error, kind LimitKind) {
+	t.Helper()
+	limitErr := &ErrLimitExceeded{}
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("must return ErrLimitExceeded, returned = %v", err)
+	}
+	if limitErr.Kind != kind {
+		t.Errorf("wrong limit, want => %v got => %v", kind, limitErr.Kind)
+	}
+}
+
+func TestService_Pay_maxPaymentLimit(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 10_000_00)
+	err := svc.SetLimits(types.Limits{AccountID: account.ID, MaxPayment: 100_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 100_00, "auto")
+	if err != nil {
+		t.Fatalf("Pay(): error = %v", err)
+	}
+	_, err = svc.Pay(account.ID, 100_01, "auto")
+	checkLimitExceeded(t, err, LimitMaxPayment)
+}
+
+func TestService_Pay_dailyLimit(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 10_000_00)
+	err := svc.SetLimits(types.Limits{AccountID: account.ID, Daily: 150_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.Pay(account.ID, 100_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Repeat(payment.ID)
+	checkLimitExceeded(t, err, LimitDaily)
+
+	//отменённые платежи не учитываются
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Repeat(payment.ID)
+	if err != nil {
+		t.Fatalf("Repeat(): error = %v", err)
+	}
+
+	clock.Add(24 * time.Hour)
+	_, err = svc.Pay(account.ID, 100_00, "auto")
+	if err != nil {
+		t.Fatalf("Pay(): next day error = %v", err)
+	}
+}
+
+func TestService_Pay_monthlyAndCategoryLimits(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 10_000_00)
+	err := svc.SetLimits(types.Limits{
+		AccountID:  account.ID,
+		Monthly:    1_000_00,
+		Categories: map[types.PaymentCategory]types.Money{"restaurant": 500_00},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.Pay(account.ID, 400_00, "restaurant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	favorite, err := svc.FavoritePayment(payment.ID, "Babilon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.PayFromFavorite(favorite.ID)
+	checkLimitExceeded(t, err, LimitCategory)
+
+	_, err = svc.Pay(account.ID, 550_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Pay(account.ID, 60_00, "auto")
+	checkLimitExceeded(t, err, LimitMonthly)
+
+	clock.Add(11 * 24 * time.Hour)
+	_, err = svc.PayFromFavorite(favorite.ID)
+	if err != nil {
+		t.Fatalf("PayFromFavorite(): next month error = %v", err)
+	}
+}
+
+func TestService_Limits_exportImport(t *testing.T) {
+	limits := types.Limits{
+		MaxPayment: 100_00,
+		Daily:      200_00,
+		Categories: map[types.PaymentCategory]types.Money{"restaurant": 500_00, "food": 300_00},
+	}
+	svc, _, account := newTestServiceUserWithClock(t, 10_000_00)
+	limits.AccountID = account.ID
+	err := svc.SetLimits(limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limits.AccountID = account.ID
+	got := imported.FindLimits(account.ID)
+	if !reflect.DeepEqual(limits, got) {
+		t.Errorf("Import(): want => %v got => %v", limits, got)
+	}
+}
+
+func TestService_SetLimits_fail(t *testing.T) {
+	svc := &Service{}
+	err := svc.SetLimits(types.Limits{AccountID: 1, Daily: 100})
+	if err != ErrAccountNotFound {
+		t.Errorf("SetLimits(): must return ErrAccountNotFound, returned = %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package wallet
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+var ErrMerchantNotFound = errors.New("merchant not found")
+var ErrInvalidMerchantName = errors.New("merchant name must not be empty or contain ';' or '|'")
+
+//RegisterMerchant - добавляет продавца. Выручка продавца перечисляется на settlementAccountID,
+//category - категория его платежей, если при оплате она не указана.
+func (s *Service) RegisterMerchant(name string, category types.PaymentCategory, settlementAccountID int64) (*types.Merchant, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.ContainsAny(name, ";|") {
+		return nil, ErrInvalidMerchantName
+	}
+	category, err := s.resolveCategory(category)
+	if err != nil {
+		return nil, err
+	}
+	if !validCategoryCode(category) {
+		return nil, ErrInvalidCategory
+	}
+	account, err := s.FindAccountByID(settlementAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Status == types.AccountStatusClosed {
+		return nil, ErrAccountClosed
+	}
+
+	s.nextMerchantID++
+	merchant := &types.Merchant{
+		ID:                  s.nextMerchantID,
+		Name:                name,
+		Category:            category,
+		SettlementAccountID: settlementAccountID,
+	}
+	s.merchants = append(s.merchants, merchant)
+	return merchant, nil
+}
+
+//FindMerchantByID - находит продавца по ID
+func (s *Service) FindMerchantByID(merchantID int64) (*types.Merchant, error) {
+	for _, merchant := range s.merchants {
+		if merchant.ID == merchantID {
+			return merchant, nil
+		}
+	}
+	return nil, ErrMerchantNotFound
+}
+
+//ListMerchants - продавцы в порядке регистрации
+func (s *Service) ListMerchants() []types.Merchant {
+	merchants := make([]types.Merchant, 0, len(s.merchants))
+	for _, merchant := range s.merchants {
+		merchants = append(merchants, *merchant)
+	}
+	return merchants
+}
+
+//PayMerchant - оплата продавцу. Если категория не указана, берётся категория продавца.
+func (s *Service) PayMerchant(accountID int64, merchantID int64, amount types.Money, category ...types.PaymentCategory) (*types.Payment, error) {
+	merchant, err := s.FindMerchantByID(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	paymentCategory := merchant.Category
+	if len(category) != 0 && category[0] != "" {
+		paymentCategory = category[0]
+	}
+	return s.pay(accountID, amount, paymentCategory, merchant.ID)
+}
+
+//MerchantSettlement - выручка продавца за один день
+type MerchantSettlement struct {
+	MerchantID          int64
+	SettlementAccountID int64
+	Day                 string //Дата в формате 2006-01-02
+	Count               int
+	Total               types.Money //Сумма проведённых (OK) платежей
+	Refunded            types.Money //Сумма частичных возвратов по этим платежам
+	Net                 types.Money //Total - Refunded, к перечислению продавцу
+}
+
+//MerchantSettlementReport - выручка продавцов по дням за период [from, to]; нулевая граница
+//не ограничивает период. Учитываются только проведённые платежи, отменённые не попадают в отчёт.
+//Отчёт отсортирован по продавцу, затем по дню.
+func (s *Service) MerchantSettlementReport(from time.Time, to time.Time) ([]MerchantSettlement, error) {
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return nil, ErrInvalidQuery
+	}
+
+	type key struct {
+		merchantID int64
+		day        string
+	}
+	settlements := map[key]*MerchantSettlement{}
+	for _, payment := range s.payments {
+		if payment.MerchantID == 0 || payment.Status != types.PaymentStatusOk {
+			continue
+		}
+		if !from.IsZero() && payment.CreatedAt.Before(from) || !to.IsZero() && payment.CreatedAt.After(to) {
+			continue
+		}
+		day := payment.CreatedAt.Format("2006-01-02")
+		settlement, ok := settlements[key{payment.MerchantID, day}]
+		if !ok {
+			settlement = &MerchantSettlement{MerchantID: payment.MerchantID, Day: day}
+			merchant, err := s.FindMerchantByID(payment.MerchantID)
+			if err == nil {
+				settlement.SettlementAccountID = merchant.SettlementAccountID
+			}
+			settlements[key{payment.MerchantID, day}] = settlement
+		}
+		settlement.Count++
+		settlement.Total += payment.Amount
+		settlement.Refunded += s.refunded(payment.ID)
+		settlement.Net = settlement.Total - settlement.Refunded
+	}
+
+	report := make([]MerchantSettlement, 0, len(settlements))
+	for _, settlement := range settlements {
+		report = append(report, *settlement)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].MerchantID != report[j].MerchantID {
+			return report[i].MerchantID < report[j].MerchantID
+		}
+		return report[i].Day < report[j].Day
+	})
+	return report, nil
+}
+
+//exportMerchants - сохраняет продавцов в dir/merchants.dump: ID;название;категория;счёт
+func (s *Service) exportMerchants(dir string) error {
+	records := make([]string, 0, len(s.merchants))
+	for _, merchant := range s.merchants {
+		records = append(records, strconv.FormatInt(merchant.ID, 10)+";"+merchant.Name+";"+
+			string(merchant.Category)+";"+strconv.FormatInt(merchant.SettlementAccountID, 10))
+	}
+	return writeDump(dir, "merchants.dump", records)
+}
+
+//importMerchants - загружает продавцов из dir/merchants.dump
+func (s *Service) importMerchants(dir string) error {
+	values, err := readDump(dir, "merchants.dump")
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		if len(value) < 4 {
+			return ErrInvalidDump
+		}
+		id, err := strconv.ParseInt(value[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		settlementAccountID, err := strconv.ParseInt(value[3], 10, 64)
+		if err != nil {
+			return err
+		}
+		s.merchants = append(s.merchants, &types.Merchant{
+			ID:                  id,
+			Name:                value[1],
+			Category:            types.PaymentCategory(value[2]),
+			SettlementAccountID: settlementAccountID,
+		})
+		if id > s.nextMerchantID {
+			s.nextMerchantID = id
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+//addTestMerchant - продавец "Korvon" с категорией food и отдельным счётом для расчётов
+func (s *testServiceUser) addTestMerchant(t *testing.T) *types.Merchant {
+	t.Helper()
+	settlement := s.addTestAccount(t, "+992000000002", 0)
+	merchant, err := s.RegisterMerchant("Korvon", "food", settlement.ID)
+	if err != nil {
+		t.Fatalf("RegisterMerchant(): error = %v", err)
+	}
+	return merchant
+}
+
+func TestService_RegisterMerchant_validation(t *testing.T) {
+	svc, _, _ := newTestServiceUserWithClock(t, 1_000_00)
+	svc.addTestMerchant(t)
+
+	_, err := svc.RegisterMerchant(" ", "food", 1)
+	if err != ErrInvalidMerchantName {
+		t.Errorf("RegisterMerchant(): must return ErrInvalidMerchantName, returned = %v", err)
+	}
+	_, err = svc.RegisterMerchant("Dushanbe City", "food", 100)
+	if err != ErrAccountNotFound {
+		t.Errorf("RegisterMerchant(): must return ErrAccountNotFound, returned = %v", err)
+	}
+	_, err = svc.FindMerchantByID(100)
+	if err != ErrMerchantNotFound {
+		t.Errorf("FindMerchantByID(): must return ErrMerchantNotFound, returned = %v", err)
+	}
+}
+
+func TestService_PayMerchant(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	merchant := svc.addTestMerchant(t)
+
+	payment, err := svc.PayMerchant(account.ID, merchant.ID, 10_00)
+	if err != nil {
+		t.Fatalf("PayMerchant(): error = %v", err)
+	}
+	if payment.MerchantID != merchant.ID || payment.Category != "food" {
+		t.Errorf("PayMerchant(): wrong payment = %v", payment)
+	}
+	payment, err = svc.PayMerchant(account.ID, merchant.ID, 10_00, "restaurant")
+	if err != nil || payment.Category != "restaurant" {
+		t.Errorf("PayMerchant(): category must be overridden, payment = %v, error = %v", payment, err)
+	}
+	repeated, err := svc.Repeat(payment.ID)
+	if err != nil || repeated.MerchantID != merchant.ID {
+		t.Errorf("Repeat(): merchant must be kept, payment = %v, error = %v", repeated, err)
+	}
+	_, err = svc.PayMerchant(account.ID, 100, 10_00)
+	if err != ErrMerchantNotFound {
+		t.Errorf("PayMerchant(): must return ErrMerchantNotFound, returned = %v", err)
+	}
+
+	page, err := svc.QueryPayments(PaymentQuery{MerchantIDs: []int64{merchant.ID}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Payments) != 3 {
+		t.Errorf("QueryPayments(): want => 3 merchant payments got => %v", len(page.Payments))
+	}
+}
+
+func TestService_MerchantSettlementReport(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 1_000_00)
+	merchant := svc.addTestMerchant(t)
+	other, err := svc.RegisterMerchant("Auchan", "food", account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pay := func(merchantID int64, amount types.Money) *types.Payment {
+		payment, err := svc.PayMerchant(account.ID, merchantID, amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = svc.Complete(payment.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payment
+	}
+	first := pay(merchant.ID, 10_00)
+	pay(merchant.ID, 20_00)
+	rejected := pay(merchant.ID, 40_00)
+	pay(other.ID, 5_00)
+	_, err = svc.PayMerchant(account.ID, merchant.ID, 7_00) //не проведён
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Refund(first.ID, 3_00, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Reject(rejected.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(24 * time.Hour)
+	pay(merchant.ID, 50_00)
+
+	got, err := svc.MerchantSettlementReport(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("MerchantSettlementReport(): error = %v", err)
+	}
+	want := []MerchantSettlement{
+		{MerchantID: merchant.ID, SettlementAccountID: 2, Day: "2020-11-20", Count: 2, Total: 30_00, Refunded: 3_00, Net: 27_00},
+		{MerchantID: merchant.ID, SettlementAccountID: 2, Day: "2020-11-21", Count: 1, Total: 50_00, Net: 50_00},
+		{MerchantID: other.ID, SettlementAccountID: account.ID, Day: "2020-11-20", Count: 1, Total: 5_00, Net: 5_00},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("MerchantSettlementReport(): want => %v got => %v", want, got)
+	}
+
+	got, err = svc.MerchantSettlementReport(clock.Now().Add(-time.Hour), time.Time{})
+	if err != nil || len(got) != 1 || got[0].Day != "2020-11-21" {
+		t.Errorf("MerchantSettlementReport(): wrong period report = %v, error = %v", got, err)
+	}
+	_, err = svc.MerchantSettlementReport(clock.Now(), clock.Now().Add(-time.Hour))
+	if err != ErrInvalidQuery {
+		t.Errorf("MerchantSettlementReport(): must return ErrInvalidQuery, returned = %v", err)
+	}
+}
+
+func TestService_Merchants_exportImport(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	merchant := svc.addTestMerchant(t)
+	_, err := svc.PayMerchant(account.ID, merchant.ID, 10_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(svc.ListMerchants(), imported.ListMerchants()) {
+		t.Errorf("Import(): want => %v got => %v", svc.ListMerchants(), imported.ListMerchants())
+	}
+	if imported.payments[0].MerchantID != merchant.ID {
+		t.Errorf("Import(): payment must keep merchant = %v", imported.payments[0])
+	}
+	next, err := imported.RegisterMerchant("Auchan", "food", account.ID)
+	if err != nil || next.ID != merchant.ID+1 {
+		t.Errorf("RegisterMerchant(): wrong ID after import = %v, error = %v", next, err)
+	}
+}
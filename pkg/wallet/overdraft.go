@@ -0,0 +1,77 @@
+package wallet
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+var ErrOverdraftBelowDebt = errors.New("overdraft limit is below current debt")
+
+//OverdraftStatus - состояние аккаунта, находящегося в овердрафте
+type OverdraftStatus struct {
+	AccountID    int64
+	Balance      types.Money
+	Limit        types.Money
+	Since        time.Time
+	GraceEnds    time.Time
+	GraceExpired bool //Беспроцентный период закончился, а долг не погашен
+}
+
+//SetOverdraft - задаёт кредитную линию аккаунта: баланс может опускаться до -limit,
+//а долг не облагается процентами в течение grace с момента ухода в минус
+func (s *Service) SetOverdraft(accountID int64, limit types.Money, grace time.Duration) error {
+	if limit < 0 || grace < 0 {
+		return ErrAmountMustBePositive
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	err = checkActive(account)
+	if err != nil {
+		return err
+	}
+	if account.Balance < 0 && -account.Balance > limit {
+		return ErrOverdraftBelowDebt
+	}
+
+	account.Overdraft = limit
+	account.OverdraftGrace = grace
+	account.UpdatedAt = s.now()
+	s.recordAccount(OperationOverdraft, account)
+	return nil
+}
+
+//updateOverdraft - отмечает, когда баланс ушёл в минус, и сбрасывает отметку после погашения
+func (s *Service) updateOverdraft(account *types.Account) {
+	if account.Balance >= 0 {
+		account.OverdraftSince = time.Time{}
+		return
+	}
+	if account.OverdraftSince.IsZero() {
+		account.OverdraftSince = s.now()
+	}
+}
+
+//OverdraftReport - аккаунты, баланс которых сейчас отрицательный
+func (s *Service) OverdraftReport() []OverdraftStatus {
+	now := s.now()
+	report := []OverdraftStatus{}
+	for _, account := range s.accounts {
+		if account.Balance >= 0 {
+			continue
+		}
+		graceEnds := account.OverdraftSince.Add(account.OverdraftGrace)
+		report = append(report, OverdraftStatus{
+			AccountID:    account.ID,
+			Balance:      account.Balance,
+			Limit:        account.Overdraft,
+			Since:        account.OverdraftSince,
+			GraceEnds:    graceEnds,
+			GraceExpired: now.After(graceEnds),
+		})
+	}
+	return report
+}
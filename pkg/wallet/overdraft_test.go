@@ -0,0 +1,96 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestService_Pay_overdraft(t *testing.T) {
+	clock := newTestClock()
+	svc := &Service{}
+	svc.SetClock(clock.Now)
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Deposit(account.ID, 100_00)
+
+	_, err = svc.Pay(account.ID, 150_00, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Fatalf("Pay(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+
+	err = svc.SetOverdraft(account.ID, 100_00, 72*time.Hour)
+	if err != nil {
+		t.Fatalf("SetOverdraft(): error = %v", err)
+	}
+	_, err = svc.Pay(account.ID, 150_00, "auto")
+	if err != nil {
+		t.Fatalf("Pay(): error = %v", err)
+	}
+	if account.Balance != -50_00 || !account.OverdraftSince.Equal(clock.Now()) {
+		t.Errorf("Pay(): wrong account = %+v", account)
+	}
+	_, err = svc.Pay(account.ID, 50_01, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("Pay(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	err = svc.SetOverdraft(account.ID, 40_00, 0)
+	if err != ErrOverdraftBelowDebt {
+		t.Errorf("SetOverdraft(): must return ErrOverdraftBelowDebt, returned = %v", err)
+	}
+
+	clock.Add(96 * time.Hour)
+	report := svc.OverdraftReport()
+	if len(report) != 1 || report[0].AccountID != account.ID || report[0].Balance != -50_00 || !report[0].GraceExpired {
+		t.Errorf("OverdraftReport(): wrong report = %+v", report)
+	}
+
+	err = svc.Deposit(account.ID, 30_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != -20_00 || len(svc.OverdraftReport()) != 1 {
+		t.Errorf("Deposit(): debt must be partially repaid, account = %+v", account)
+	}
+	err = svc.Deposit(account.ID, 50_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 30_00 || !account.OverdraftSince.IsZero() || len(svc.OverdraftReport()) != 0 {
+		t.Errorf("Deposit(): debt must be repaid, account = %+v", account)
+	}
+}
+
+func TestService_Overdraft_exportImport(t *testing.T) {
+	clock := newTestClock()
+	svc := &Service{}
+	svc.SetClock(clock.Now)
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.SetOverdraft(account.ID, 100_00, time.Hour)
+	_, err = svc.Pay(account.ID, 10_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := imported.FindAccountByID(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Overdraft != 100_00 || got.OverdraftGrace != time.Hour || !got.OverdraftSince.Equal(account.OverdraftSince) {
+		t.Errorf("Import(): wrong account = %+v", got)
+	}
+}
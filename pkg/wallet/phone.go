@@ -0,0 +1,121 @@
+package wallet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+//DefaultCountryCode - код страны для номеров, записанных без него (9 цифр местного номера)
+const DefaultCountryCode = "992"
+
+//phoneRule - правило для номеров страны: сколько цифр после кода страны
+type phoneRule struct {
+	country string
+	digits  int
+}
+
+//phoneRules - правила стран, номера остальных стран проверяются только на соответствие E.164
+var phoneRules = map[string]phoneRule{
+	"992": {country: "Tajikistan", digits: 9},
+}
+
+//ErrInvalidPhone - номер телефона нельзя привести к формату E.164
+type ErrInvalidPhone struct {
+	Phone  types.Phone
+	Reason string
+}
+
+func (e *ErrInvalidPhone) Error() string {
+	return fmt.Sprintf("invalid phone %q: %s", e.Phone, e.Reason)
+}
+
+//ErrDuplicatePhone - в импортируемых данных у двух аккаунтов один и тот же номер
+type ErrDuplicatePhone struct {
+	Phone       types.Phone
+	AccountID   int64
+	DuplicateID int64
+}
+
+func (e *ErrDuplicatePhone) Error() string {
+	return fmt.Sprintf("phone %s of account %d is already used by account %d", e.Phone, e.DuplicateID, e.AccountID)
+}
+
+//NormalizePhone - приводит номер к формату E.164 (+992000000001). Пробелы, дефисы, точки
+//и скобки убираются, 00 в начале заменяется на +, номер без кода страны считается номером
+//DefaultCountryCode.
+func NormalizePhone(phone types.Phone) (types.Phone, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, string(phone))
+
+	switch {
+	case strings.HasPrefix(digits, "+"):
+		digits = digits[1:]
+	case len(digits) == phoneRules[DefaultCountryCode].digits:
+		digits = DefaultCountryCode + digits
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	}
+
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return "", &ErrInvalidPhone{Phone: phone, Reason: "must contain only digits"}
+	}
+	if digits[0] == '0' {
+		return "", &ErrInvalidPhone{Phone: phone, Reason: "country code must not start with 0"}
+	}
+	if len(digits) < 8 || len(digits) > 15 {
+		return "", &ErrInvalidPhone{Phone: phone, Reason: "must have from 8 to 15 digits"}
+	}
+
+	//коды стран не пересекаются по префиксам, поэтому подходит не больше одного правила
+	for code, rule := range phoneRules {
+		if strings.HasPrefix(digits, code) && len(digits) != len(code)+rule.digits {
+			return "", &ErrInvalidPhone{
+				Phone:  phone,
+				Reason: fmt.Sprintf("%s numbers must have %d digits after +%s", rule.country, rule.digits, code),
+			}
+		}
+	}
+	return types.Phone("+" + digits), nil
+}
+
+//FindAccountByPhone - находит аккаунт по номеру телефона, записанному в любом формате
+func (s *Service) FindAccountByPhone(phone types.Phone) (*types.Account, error) {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range s.accounts {
+		if account.Phone == phone {
+			return account, nil
+		}
+	}
+	return nil, ErrAccountNotFound
+}
+
+//addImportedAccount - добавляет аккаунт из дампа, приводя номер к E.164.
+//Старые дампы могли содержать один номер в разных записях - такой дамп не загружается.
+func (s *Service) addImportedAccount(account *types.Account) error {
+	phone, err := NormalizePhone(account.Phone)
+	if err != nil {
+		return err
+	}
+	for _, saved := range s.accounts {
+		if saved.Phone == phone && saved.ID != account.ID {
+			return &ErrDuplicatePhone{Phone: phone, AccountID: saved.ID, DuplicateID: account.ID}
+		}
+	}
+
+	account.Phone = phone
+	s.accounts = append(s.accounts, account)
+	if account.ID > s.nextAccountID {
+		s.nextAccountID = account.ID
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package wallet
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func TestNormalizePhone_success(t *testing.T) {
+	phones := []types.Phone{
+		"+992000000001",
+		"992000000001",
+		"+992 00 000 0001",
+		"00992 (00) 000-00-01",
+		"000000001",
+		"+992.00.000.00.01",
+	}
+	for _, phone := range phones {
+		got, err := NormalizePhone(phone)
+		if err != nil {
+			t.Errorf("NormalizePhone(%q): error = %v", phone, err)
+			continue
+		}
+		if got != "+992000000001" {
+			t.Errorf("NormalizePhone(%q): want => +992000000001 got => %v", phone, got)
+		}
+	}
+
+	//номера других стран проверяются только по E.164
+	got, err := NormalizePhone("+7 912 345 67 89")
+	if err != nil || got != "+79123456789" {
+		t.Errorf("NormalizePhone(): want => +79123456789 got => %v, error = %v", got, err)
+	}
+}
+
+func TestNormalizePhone_invalid(t *testing.T) {
+	phones := []types.Phone{"", "+", "+992abc000001", "+99200000001", "+9920000000011", "+0992000000001", "12345", "+1234567890123456"}
+	for _, phone := range phones {
+		_, err := NormalizePhone(phone)
+		phoneErr := &ErrInvalidPhone{}
+		if !errors.As(err, &phoneErr) {
+			t.Errorf("NormalizePhone(%q): must return ErrInvalidPhone, returned = %v", phone, err)
+		}
+	}
+}
+
+func TestService_RegisterAccount_normalizedDuplicates(t *testing.T) {
+	svc := &Service{}
+	account, err := svc.RegisterAccount("+992 00 000 0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Phone != "+992000000001" {
+		t.Errorf("RegisterAccount(): phone must be normalized, got = %v", account.Phone)
+	}
+
+	for _, phone := range []types.Phone{"+992000000001", "992000000001", "000000001"} {
+		_, err = svc.RegisterAccount(phone)
+		if err != ErrPhoneRegistered {
+			t.Errorf("RegisterAccount(%q): must return ErrPhoneRegistered, returned = %v", phone, err)
+		}
+	}
+	_, err = svc.RegisterAccount("+992 123")
+	phoneErr := &ErrInvalidPhone{}
+	if !errors.As(err, &phoneErr) {
+		t.Errorf("RegisterAccount(): must return ErrInvalidPhone, returned = %v", err)
+	}
+
+	got, err := svc.FindAccountByPhone("992-00-000-00-01")
+	if err != nil || got != account {
+		t.Errorf("FindAccountByPhone(): want => %v got => %v, error = %v", account, got, err)
+	}
+	_, err = svc.FindAccountByPhone("+992000000002")
+	if err != ErrAccountNotFound {
+		t.Errorf("FindAccountByPhone(): must return ErrAccountNotFound, returned = %v", err)
+	}
+}
+
+func TestService_Import_legacyPhones(t *testing.T) {
+	dir := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(dir, "accounts.dump"), []byte("1;992000000001;100|2;+992 00 000 0002;200|"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := &Service{}
+	err = svc.Import(dir)
+	if err != nil {
+		t.Fatalf("Import(): error = %v", err)
+	}
+	account, err := svc.FindAccountByPhone("+992000000002")
+	if err != nil || account.ID != 2 {
+		t.Errorf("FindAccountByPhone(): wrong account = %v, error = %v", account, err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "accounts.dump"), []byte("1;992000000001;100|2;+992 00 000 0001;200|"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = (&Service{}).Import(dir)
+	duplicateErr := &ErrDuplicatePhone{}
+	if !errors.As(err, &duplicateErr) {
+		t.Fatalf("Import(): must return ErrDuplicatePhone, returned = %v", err)
+	}
+	if duplicateErr.AccountID != 1 || duplicateErr.DuplicateID != 2 {
+		t.Errorf("Import(): wrong duplicate = %+v", duplicateErr)
+	}
+
+	file := filepath.Join(dir, "legacy.dump")
+	err = ioutil.WriteFile(file, []byte("1;992000000001;100|2;992000000001;200|"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = (&Service{}).ImportFromFile(file)
+	if !errors.As(err, &duplicateErr) {
+		t.Errorf("ImportFromFile(): must return ErrDuplicatePhone, returned = %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package wallet
+
+import (
+	"sync"
+	"time"
+)
+
+//ProgressReport - состояние долгой операции сервиса (экспорт, импорт, фильтрация и т.д.)
+type ProgressReport struct {
+	Operation string        `json:"operation"`
+	Processed int           `json:"processed"` //Сколько записей уже обработано
+	Total     int           `json:"total"`     //Сколько записей всего (известно на текущий момент)
+	Percent   float64       `json:"percent"`
+	Elapsed   time.Duration `json:"elapsed"`
+	ETA       time.Duration `json:"eta"` //Оценка оставшегося времени, 0 - если оценить нельзя
+	Done      bool          `json:"done"`
+}
+
+//ProgressFunc - получатель отчётов о прогрессе. Может вызываться из разных горутин,
+//но не параллельно.
+type ProgressFunc func(report ProgressReport)
+
+//SetProgress - задаёт получателя отчётов о прогрессе долгих операций, nil - отключает отчёты
+func (s *Service) SetProgress(progress ProgressFunc) {
+	s.progress = progress
+}
+
+//ProgressChannel - адаптер для тех, кому удобнее читать отчёты из канала, как у SumPaymentsWithProgress.
+//Операция никогда не ждёт читателя: если буфер заполнен, промежуточный отчёт пропускается,
+//а завершающий (Done) вытесняет самый старый. Читатель должен остановиться на отчёте с Done.
+func ProgressChannel(size int) (ProgressFunc, <-chan ProgressReport) {
+	if size < 1 {
+		size = 1
+	}
+	ch := make(chan ProgressReport, size)
+	return func(report ProgressReport) {
+		select {
+		case ch <- report:
+			return
+		default:
+		}
+		if report.Done {
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- report
+		}
+	}, ch
+}
+
+//progressTracker - считает обработанные записи одной операции и отправляет отчёты
+//не чаще, чем раз на процент, чтобы не тормозить саму операцию
+type progressTracker struct {
+	mu        sync.Mutex
+	operation string
+	report    ProgressFunc
+	now       func() time.Time
+	started   time.Time
+	processed int
+	total     int
+	percent   int
+}
+
+//track - начинает отслеживание операции; если получатель не задан, возвращает nil,
+//а методы nil-трекера ничего не делают
+func (s *Service) track(operation string, total int) *progressTracker {
+	if s.progress == nil {
+		return nil
+	}
+	tracker := &progressTracker{
+		operation: operation,
+		report:    s.progress,
+		now:       s.now,
+		started:   s.now(),
+		total:     total,
+		percent:   -1,
+	}
+	tracker.send(false)
+	return tracker
+}
+
+//expect - увеличивает ожидаемое количество записей (когда оно становится известно по ходу операции)
+func (t *progressTracker) expect(n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total += n
+}
+
+//add - отмечает, что ещё n записей обработано
+func (t *progressTracker) add(n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.processed += n
+	if t.total > 0 && t.processed*100/t.total != t.percent {
+		t.send(false)
+	}
+}
+
+//done - отправляет завершающий отчёт
+func (t *progressTracker) done() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.send(true)
+}
+
+//send - отправляет отчёт, вызывается под t.mu
+func (t *progressTracker) send(done bool) {
+	report := ProgressReport{
+		Operation: t.operation,
+		Processed: t.processed,
+		Total:     t.total,
+		Elapsed:   t.now().Sub(t.started),
+		Done:      done,
+	}
+	if t.total > 0 {
+		t.percent = t.processed * 100 / t.total
+		report.Percent = float64(t.processed) * 100 / float64(t.total)
+	}
+	if done {
+		report.Percent = 100
+	} else if t.processed > 0 && t.total > t.processed {
+		report.ETA = time.Duration(float64(report.Elapsed) * float64(t.total-t.processed) / float64(t.processed))
+	}
+	t.report(report)
+}
@@ -0,0 +1,101 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func collectProgress(svc *Service) *[]ProgressReport {
+	reports := []ProgressReport{}
+	svc.SetProgress(func(report ProgressReport) {
+		reports = append(reports, report)
+	})
+	return &reports
+}
+
+func checkProgress(t *testing.T, reports []ProgressReport, operation string, total int) {
+	t.Helper()
+	if len(reports) < 2 {
+		t.Fatalf("%v: too few reports = %v", operation, reports)
+	}
+	last := reports[len(reports)-1]
+	if !last.Done || last.Operation != operation || last.Processed != total || last.Total != total || last.Percent != 100 {
+		t.Errorf("%v: wrong final report = %+v", operation, last)
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i].Processed < reports[i-1].Processed {
+			t.Errorf("%v: progress went back, reports = %+v", operation, reports)
+			break
+		}
+	}
+}
+
+func TestService_Progress_exportImport(t *testing.T) {
+	svc, _ := newTestQueryService(t)
+	reports := collectProgress(svc)
+
+	dir := t.TempDir()
+	err := svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, *reports, "export", 9)
+
+	imported := &Service{}
+	reports = collectProgress(imported)
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, *reports, "import", 9)
+}
+
+func TestService_Progress_filter(t *testing.T) {
+	clock := newTestClock()
+	svc := &Service{}
+	svc.SetClock(clock.Now)
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Deposit(account.ID, 1_000_000_00)
+	for i := 0; i < 3000; i++ {
+		svc.Pay(account.ID, types.Money(i+1), "auto")
+	}
+
+	reports := []ProgressReport{}
+	svc.SetProgress(func(report ProgressReport) {
+		clock.Add(time.Second)
+		reports = append(reports, report)
+	})
+	_, err = svc.FilterPaymentsContext(context.Background(), func(payment types.Payment) bool { return true }, ScanOptions{Workers: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, reports, "filter", 3000)
+
+	for _, report := range reports {
+		if report.Processed > 0 && report.Processed < report.Total && report.ETA <= 0 {
+			t.Errorf("FilterPaymentsContext(): ETA must be estimated, report = %+v", report)
+		}
+	}
+}
+
+func TestProgressChannel_neverBlocks(t *testing.T) {
+	svc, _ := newTestQueryService(t)
+	progress, ch := ProgressChannel(1)
+	svc.SetProgress(progress)
+
+	err := svc.Export(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := <-ch
+	if !report.Done {
+		t.Errorf("ProgressChannel(): final report must be kept, got = %+v", report)
+	}
+}
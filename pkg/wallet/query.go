@@ -0,0 +1,214 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+var ErrInvalidQuery = errors.New("invalid payment query")
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+//PaymentSort представляет собой поле, по которому сортируются результаты запроса
+type PaymentSort string
+
+//Поддерживаемые варианты сортировки
+const (
+	SortByNone      PaymentSort = ""
+	SortByCreatedAt PaymentSort = "created"
+	SortByAmount    PaymentSort = "amount"
+)
+
+//PaymentQuery - декларативный запрос к платежам. Пустые поля не ограничивают выборку.
+//Запрос сериализуется в JSON, поэтому его можно передать по API или сохранить.
+type PaymentQuery struct {
+	AccountIDs  []int64                 `json:"accountIds,omitempty"`
+	Categories  []types.PaymentCategory `json:"categories,omitempty"`
+	Statuses    []types.PaymentStatus   `json:"statuses,omitempty"`
+	MerchantIDs []int64                 `json:"merchantIds,omitempty"`
+	MinAmount   types.Money             `json:"minAmount,omitempty"`
+	MaxAmount   types.Money             `json:"maxAmount,omitempty"`
+	CreatedFrom time.Time               `json:"createdFrom,omitempty"`
+	CreatedTo   time.Time               `json:"createdTo,omitempty"`
+	Text        string                  `json:"text,omitempty"` //Подстрока в ID или категории, без учёта регистра
+	SortBy      PaymentSort             `json:"sortBy,omitempty"`
+	Desc        bool                    `json:"desc,omitempty"`
+	Limit       int                     `json:"limit,omitempty"`  //0 - без ограничения
+	Cursor      string                  `json:"cursor,omitempty"` //NextCursor предыдущей страницы
+}
+
+//PaymentPage - одна страница результатов запроса
+type PaymentPage struct {
+	Payments   []types.Payment `json:"payments"`
+	NextCursor string          `json:"nextCursor,omitempty"` //Пустой, если страниц больше нет
+}
+
+//Encode - сериализует запрос в JSON
+func (q PaymentQuery) Encode() ([]byte, error) {
+	return json.Marshal(q)
+}
+
+//DecodePaymentQuery - восстанавливает запрос из JSON
+func DecodePaymentQuery(data []byte) (PaymentQuery, error) {
+	query := PaymentQuery{}
+	err := json.Unmarshal(data, &query)
+	if err != nil {
+		return PaymentQuery{}, err
+	}
+	return query, nil
+}
+
+//validate - проверяет, что запрос непротиворечив
+func (q PaymentQuery) validate() error {
+	if q.MinAmount < 0 || q.MaxAmount < 0 || q.Limit < 0 {
+		return ErrInvalidQuery
+	}
+	if q.MaxAmount != 0 && q.MinAmount > q.MaxAmount {
+		return ErrInvalidQuery
+	}
+	if !q.CreatedFrom.IsZero() && !q.CreatedTo.IsZero() && q.CreatedFrom.After(q.CreatedTo) {
+		return ErrInvalidQuery
+	}
+	switch q.SortBy {
+	case SortByNone, SortByCreatedAt, SortByAmount:
+	default:
+		return ErrInvalidQuery
+	}
+	return nil
+}
+
+//Match - проверяет, подходит ли платёж под условия запроса
+func (q PaymentQuery) Match(payment types.Payment) bool {
+	if len(q.AccountIDs) != 0 {
+		found := false
+		for _, id := range q.AccountIDs {
+			if id == payment.AccountID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(q.Categories) != 0 {
+		found := false
+		for _, category := range q.Categories {
+			if category == payment.Category {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(q.Statuses) != 0 {
+		found := false
+		for _, status := range q.Statuses {
+			if status == payment.Status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(q.MerchantIDs) != 0 {
+		found := false
+		for _, id := range q.MerchantIDs {
+			if id == payment.MerchantID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if payment.Amount < q.MinAmount {
+		return false
+	}
+	if q.MaxAmount != 0 && payment.Amount > q.MaxAmount {
+		return false
+	}
+	if !q.CreatedFrom.IsZero() && payment.CreatedAt.Before(q.CreatedFrom) {
+		return false
+	}
+	if !q.CreatedTo.IsZero() && payment.CreatedAt.After(q.CreatedTo) {
+		return false
+	}
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		if !strings.Contains(strings.ToLower(payment.ID), text) &&
+			!strings.Contains(strings.ToLower(string(payment.Category)), text) {
+			return false
+		}
+	}
+	return true
+}
+
+//QueryPayments - выполняет запрос, разбивая платежи на goroutines частей,
+//которые проверяются параллельно. Порядок платежей сохраняется.
+func (s *Service) QueryPayments(query PaymentQuery, goroutines int) (PaymentPage, error) {
+	err := query.validate()
+	if err != nil {
+		return PaymentPage{}, err
+	}
+
+	found, err := s.FilterPaymentsContext(context.Background(), query.Match, ScanOptions{Workers: goroutines})
+	if err != nil {
+		return PaymentPage{}, err
+	}
+
+	switch query.SortBy {
+	case SortByCreatedAt:
+		sort.SliceStable(found, func(i, j int) bool {
+			if query.Desc {
+				return found[i].CreatedAt.After(found[j].CreatedAt)
+			}
+			return found[i].CreatedAt.Before(found[j].CreatedAt)
+		})
+	case SortByAmount:
+		sort.SliceStable(found, func(i, j int) bool {
+			if query.Desc {
+				return found[i].Amount > found[j].Amount
+			}
+			return found[i].Amount < found[j].Amount
+		})
+	default:
+		if query.Desc {
+			for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+				found[i], found[j] = found[j], found[i]
+			}
+		}
+	}
+
+	//курсор - идентификатор последнего платежа предыдущей страницы
+	if query.Cursor != "" {
+		position := -1
+		for i, payment := range found {
+			if payment.ID == query.Cursor {
+				position = i
+				break
+			}
+		}
+		if position == -1 {
+			return PaymentPage{}, ErrInvalidCursor
+		}
+		found = found[position+1:]
+	}
+
+	page := PaymentPage{Payments: found}
+	if query.Limit != 0 && len(found) > query.Limit {
+		page.Payments = found[:query.Limit]
+		page.NextCursor = page.Payments[query.Limit-1].ID
+	}
+	return page, nil
+}
@@ -0,0 +1,137 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func newTestQueryService(t *testing.T) (*Service, *testClock) {
+	svc, clock, first := newTestServiceUserWithClock(t, 1_000_00)
+	second := svc.addTestAccount(t, "+992000000002", 1_000_00)
+
+	payments := []struct {
+		accountID int64
+		amount    types.Money
+		category  types.PaymentCategory
+	}{
+		{first.ID, 10_00, "auto"},
+		{second.ID, 20_00, "food"},
+		{first.ID, 30_00, "restaurant"},
+		{first.ID, 40_00, "auto"},
+		{second.ID, 50_00, "auto"},
+		{first.ID, 60_00, "food"},
+		{first.ID, 70_00, "auto"},
+	}
+	for _, payment := range payments {
+		clock.Add(time.Hour)
+		_, err := svc.Pay(payment.accountID, payment.amount, payment.category)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return svc.Service, clock
+}
+
+func amountsOf(payments []types.Payment) []types.Money {
+	amounts := []types.Money{}
+	for _, payment := range payments {
+		amounts = append(amounts, payment.Amount)
+	}
+	return amounts
+}
+
+func TestService_QueryPayments_filters(t *testing.T) {
+	svc, clock := newTestQueryService(t)
+	start := newTestClock().Now()
+
+	query := PaymentQuery{
+		AccountIDs:  []int64{1},
+		Categories:  []types.PaymentCategory{"auto", "food"},
+		MinAmount:   20_00,
+		CreatedFrom: start.Add(2 * time.Hour),
+		CreatedTo:   clock.Now().Add(-time.Hour),
+	}
+	page, err := svc.QueryPayments(query, 3)
+	if err != nil {
+		t.Fatalf("QueryPayments(): error = %v", err)
+	}
+
+	want := []types.Money{40_00, 60_00}
+	if got := amountsOf(page.Payments); !reflect.DeepEqual(want, got) {
+		t.Errorf("QueryPayments(): want => %v got => %v", want, got)
+	}
+}
+
+func TestService_QueryPayments_empty(t *testing.T) {
+	svc, _ := newTestQueryService(t)
+
+	page, err := svc.QueryPayments(PaymentQuery{Text: "pharmacy"}, 4)
+	if err != nil {
+		t.Fatalf("QueryPayments(): error = %v", err)
+	}
+	if len(page.Payments) != 0 || page.NextCursor != "" {
+		t.Errorf("QueryPayments(): must return empty page, returned = %v", page)
+	}
+}
+
+func TestService_QueryPayments_sortAndPaginate(t *testing.T) {
+	svc, _ := newTestQueryService(t)
+
+	query := PaymentQuery{Categories: []types.PaymentCategory{"auto"}, SortBy: SortByAmount, Desc: true, Limit: 3}
+	got := []types.Money{}
+	pages := 0
+	for {
+		page, err := svc.QueryPayments(query, 2)
+		if err != nil {
+			t.Fatalf("QueryPayments(): error = %v", err)
+		}
+		pages++
+		got = append(got, amountsOf(page.Payments)...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	want := []types.Money{70_00, 50_00, 40_00, 10_00}
+	if !reflect.DeepEqual(want, got) || pages != 2 {
+		t.Errorf("QueryPayments(): want => %v got => %v in %v pages", want, got, pages)
+	}
+}
+
+func TestService_QueryPayments_invalid(t *testing.T) {
+	svc, _ := newTestQueryService(t)
+
+	_, err := svc.QueryPayments(PaymentQuery{MinAmount: 10, MaxAmount: 5}, 1)
+	if err != ErrInvalidQuery {
+		t.Errorf("QueryPayments(): must return ErrInvalidQuery, returned = %v", err)
+	}
+	_, err = svc.QueryPayments(PaymentQuery{Cursor: "unknown"}, 1)
+	if err != ErrInvalidCursor {
+		t.Errorf("QueryPayments(): must return ErrInvalidCursor, returned = %v", err)
+	}
+}
+
+func TestPaymentQuery_EncodeDecode(t *testing.T) {
+	query := PaymentQuery{
+		AccountIDs:  []int64{1, 2},
+		Statuses:    []types.PaymentStatus{types.PaymentStatusOk},
+		CreatedFrom: newTestClock().Now(),
+		SortBy:      SortByCreatedAt,
+		Limit:       10,
+	}
+	data, err := query.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodePaymentQuery(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(query, got) {
+		t.Errorf("DecodePaymentQuery(): want => %v got => %v", query, got)
+	}
+}
@@ -0,0 +1,94 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrPaymentNotRefundable = errors.New("payment can't be refunded")
+var ErrRefundExceedsPayment = errors.New("refund exceeds paid amount")
+var ErrInvalidReason = errors.New("reason must not contain ';' or '|'")
+
+//Refund - возвращает на счёт часть суммы платежа. Возврат сохраняется отдельной записью
+//со статусом REFUND и ParentID исходного платежа. Сумма всех возвратов не может превышать платёж.
+//Кешбэк за платёж отменяется в той же доле, что и возвращённая сумма.
+func (s *Service) Refund(paymentID string, amount types.Money, reason string) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	if strings.ContainsAny(reason, ";|") {
+		return nil, ErrInvalidReason
+	}
+
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	//комиссия при частичном возврате не возвращается
+	if !refundable(payment) {
+		return nil, ErrPaymentNotRefundable
+	}
+	refunded := s.refunded(payment.ID) + amount
+	if refunded > payment.Amount {
+		return nil, ErrRefundExceedsPayment
+	}
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Status == types.AccountStatusClosed {
+		return nil, ErrAccountClosed
+	}
+
+	now := s.now()
+	account.Balance += amount - s.clawbackRefund(payment, refunded)
+	account.UpdatedAt = now
+	s.updateOverdraft(account)
+	refund := &types.Payment{
+		ID:        uuid.New().String(),
+		AccountID: payment.AccountID,
+		Amount:    amount,
+		Category:  payment.Category,
+		Status:    types.PaymentStatusRefund,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ParentID:  payment.ID,
+		Reason:    reason,
+	}
+	s.payments = append(s.payments, refund)
+	s.recordAccount(OperationRefund, account)
+	s.recordPayment(OperationRefund, refund)
+	return refund, nil
+}
+
+//refundable - платёж списан и ещё не отменён, поэтому его можно вернуть (Refund) или отменить (Reject).
+//Переводы и комиссии отдельно не возвращаются.
+func refundable(payment *types.Payment) bool {
+	if payment.Status != types.PaymentStatusOk && payment.Status != types.PaymentStatusInProgress {
+		return false
+	}
+	return payment.ToAccountID == 0 && !isFee(payment)
+}
+
+//FindRefunds - возвраты по платежу в порядке создания
+func (s *Service) FindRefunds(paymentID string) []types.Payment {
+	refunds := []types.Payment{}
+	for _, payment := range s.payments {
+		if payment.Status == types.PaymentStatusRefund && payment.ParentID == paymentID {
+			refunds = append(refunds, *payment)
+		}
+	}
+	return refunds
+}
+
+//refunded - сколько уже возвращено по платежу
+func (s *Service) refunded(paymentID string) types.Money {
+	sum := types.Money(0)
+	for _, refund := range s.FindRefunds(paymentID) {
+		sum += refund.Amount
+	}
+	return sum
+}
@@ -0,0 +1,168 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func TestService_Refund_partial(t *testing.T) {
+	s := newTestServiceUser()
+	account, payments, err := s.addAccountUser(defaultTestAccountUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := payments[0]
+
+	refund, err := s.Refund(payment.ID, 300_00, "damaged item")
+	if err != nil {
+		t.Fatalf("Refund(): error = %v", err)
+	}
+	if refund.ParentID != payment.ID || refund.Status != types.PaymentStatusRefund || refund.Reason != "damaged item" {
+		t.Errorf("Refund(): wrong refund = %+v", refund)
+	}
+	_, err = s.Refund(payment.ID, 600_00, "")
+	if err != nil {
+		t.Fatalf("Refund(): error = %v", err)
+	}
+	_, err = s.Refund(payment.ID, 100_01, "")
+	if err != ErrRefundExceedsPayment {
+		t.Errorf("Refund(): must return ErrRefundExceedsPayment, returned = %v", err)
+	}
+	if account.Balance != 9_900_00 {
+		t.Errorf("Refund(): wrong balance = %v", account.Balance)
+	}
+
+	//отмена платежа возвращает только то, что ещё не вернули
+	err = s.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != defaultTestAccountUser.balance {
+		t.Errorf("Reject(): wrong balance = %v", account.Balance)
+	}
+	_, err = s.Refund(payment.ID, 1, "")
+	if err != ErrPaymentNotRefundable {
+		t.Errorf("Refund(): must return ErrPaymentNotRefundable, returned = %v", err)
+	}
+	err = s.Reject(refund.ID)
+	if err != ErrPaymentNotRefundable {
+		t.Errorf("Reject(): must return ErrPaymentNotRefundable, returned = %v", err)
+	}
+}
+
+func TestService_Reject_twice(t *testing.T) {
+	s := newTestServiceUser()
+	account, payments, err := s.addAccountUser(defaultTestAccountUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Refund(payments[0].ID, 100_00, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Reject(payments[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Reject(payments[0].ID)
+	if err != ErrPaymentNotRefundable {
+		t.Errorf("Reject(): rejected payment must return ErrPaymentNotRefundable, returned = %v", err)
+	}
+	if account.Balance != defaultTestAccountUser.balance {
+		t.Errorf("Reject(): payment must be returned once, balance = %v", account.Balance)
+	}
+	if entries := s.movements(account.ID); len(entries) != 4 {
+		t.Errorf("movements(): want one reversal, got = %v", entries)
+	}
+}
+
+func TestService_Refund_notSpending(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 1_000_00)
+	err := svc.SetLimits(types.Limits{AccountID: account.ID, Daily: 100_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := svc.Pay(account.ID, 80_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Refund(first.ID, 80_00, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := svc.Pay(account.ID, 90_00, "auto")
+	if err != nil {
+		t.Fatalf("Pay(): refunded payment must not count in daily limit, error = %v", err)
+	}
+	_, err = svc.Refund(second.ID, 30_00, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sum := svc.SumPayments(2); sum != 60_00 {
+		t.Errorf("SumPayments(): want => %v got => %v", 60_00, sum)
+	}
+	stats, err := svc.SpendingAnalytics(PaymentQuery{}, GroupByCategory, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Total != 60_00 || stats[0].Count != 1 {
+		t.Errorf("SpendingAnalytics(): refunds must be subtracted = %v", stats)
+	}
+}
+
+func TestService_Refund_invalidReason(t *testing.T) {
+	s := newTestServiceUser()
+	_, payments, err := s.addAccountUser(defaultTestAccountUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Refund(payments[0].ID, 1, "a;b")
+	if err != ErrInvalidReason {
+		t.Errorf("Refund(): must return ErrInvalidReason, returned = %v", err)
+	}
+}
+
+func TestService_ExportAccountHistory_refundChain(t *testing.T) {
+	s := newTestServiceUser()
+	account, payments, err := s.addAccountUser(defaultTestAccountUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Pay(account.ID, 200_00, "food")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Refund(payments[0].ID, 100_00, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Refund(second.ID, 50_00, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := imported.ExportAccountHistory(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 4 ||
+		history[0].ID != payments[0].ID ||
+		history[1].ParentID != payments[0].ID || history[1].Reason != "first" ||
+		history[2].ID != second.ID ||
+		history[3].ParentID != second.ID || history[3].Amount != 50_00 {
+		t.Errorf("ExportAccountHistory(): wrong history = %+v", history)
+	}
+}
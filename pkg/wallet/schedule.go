@@ -0,0 +1,194 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+//scheduleHorizon - дальше этого срока следующее выполнение не ищется
+const scheduleHorizon = 5 * 366
+
+//weekdays - названия дней недели для расписаний weekly
+var weekdays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+//cronSchedule - разобранное расписание в формате cron: минута час день месяц день-недели.
+//Каждое поле хранится битовой маской допустимых значений.
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays uint64
+	anyDay, anyWeekday                     bool
+}
+
+//parseSchedule - разбирает расписание:
+//	daily 09:30
+//	weekly mon 09:30
+//	monthly 15 09:30 (месяцы, в которых нет такого дня, пропускаются)
+//	cron 30 9 1,15 * *
+func parseSchedule(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, ErrInvalidSchedule
+	}
+
+	switch fields[0] {
+	case "daily":
+		if len(fields) != 2 {
+			return nil, ErrInvalidSchedule
+		}
+		hour, minute, err := parseClock(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		return parseCron([]string{minute, hour, "*", "*", "*"})
+	case "weekly":
+		if len(fields) != 3 {
+			return nil, ErrInvalidSchedule
+		}
+		weekday, ok := weekdays[strings.ToLower(fields[1])]
+		if !ok {
+			return nil, ErrInvalidSchedule
+		}
+		hour, minute, err := parseClock(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		return parseCron([]string{minute, hour, "*", "*", strconv.Itoa(weekday)})
+	case "monthly":
+		if len(fields) != 3 {
+			return nil, ErrInvalidSchedule
+		}
+		hour, minute, err := parseClock(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		return parseCron([]string{minute, hour, fields[1], "*", "*"})
+	case "cron":
+		return parseCron(fields[1:])
+	}
+	return nil, ErrInvalidSchedule
+}
+
+//parseClock - разбирает время суток ЧЧ:ММ
+func parseClock(value string) (string, string, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return "", "", ErrInvalidSchedule
+	}
+	return strconv.Itoa(clock.Hour()), strconv.Itoa(clock.Minute()), nil
+}
+
+//parseCron - разбирает пять полей cron
+func parseCron(fields []string) (*cronSchedule, error) {
+	if len(fields) != 5 {
+		return nil, ErrInvalidSchedule
+	}
+
+	schedule := &cronSchedule{anyDay: fields[2] == "*", anyWeekday: fields[4] == "*"}
+	bounds := []struct {
+		mask     *uint64
+		min, max int
+	}{
+		{&schedule.minutes, 0, 59},
+		{&schedule.hours, 0, 23},
+		{&schedule.days, 1, 31},
+		{&schedule.months, 1, 12},
+		{&schedule.weekdays, 0, 6},
+	}
+	for i, bound := range bounds {
+		mask, err := parseCronField(fields[i], bound.min, bound.max)
+		if err != nil {
+			return nil, err
+		}
+		*bound.mask = mask
+	}
+	return schedule, nil
+}
+
+//parseCronField - разбирает поле cron: *, число, список через запятую, диапазон a-b и шаг /n
+func parseCronField(field string, min int, max int) (uint64, error) {
+	mask := uint64(0)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if separator := strings.Index(part, "/"); separator != -1 {
+			value, err := strconv.Atoi(part[separator+1:])
+			if err != nil || value < 1 {
+				return 0, ErrInvalidSchedule
+			}
+			step = value
+			part = part[:separator]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			value, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, ErrInvalidSchedule
+			}
+			from, to = value, value
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, ErrInvalidSchedule
+				}
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, ErrInvalidSchedule
+		}
+
+		for value := from; value <= to; value += step {
+			mask |= 1 << uint(value)
+		}
+	}
+	return mask, nil
+}
+
+//matchesDay - подходит ли дата; как в cron, если заданы и день месяца, и день недели,
+//достаточно совпадения любого из них
+func (c *cronSchedule) matchesDay(date time.Time) bool {
+	if c.months&(1<<uint(date.Month())) == 0 {
+		return false
+	}
+	day := c.days&(1<<uint(date.Day())) != 0
+	weekday := c.weekdays&(1<<uint(date.Weekday())) != 0
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
+
+//Next - первый момент по расписанию строго после after, нулевое время - если его нет
+func (c *cronSchedule) Next(after time.Time) time.Time {
+	from := after.Truncate(time.Minute).Add(time.Minute)
+	year, month, day := from.Date()
+	for i := 0; i < scheduleHorizon; i++ {
+		date := time.Date(year, month, day+i, 0, 0, 0, 0, from.Location())
+		if !c.matchesDay(date) {
+			continue
+		}
+		for hour := 0; hour < 24; hour++ {
+			if c.hours&(1<<uint(hour)) == 0 {
+				continue
+			}
+			for minute := 0; minute < 60; minute++ {
+				if c.minutes&(1<<uint(minute)) == 0 {
+					continue
+				}
+				next := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, from.Location())
+				if !next.Before(from) {
+					return next
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,219 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+//SetSchedulePolicy - сколько раз и с каким интервалом повторять платёж по расписанию,
+//если не хватило баланса. По умолчанию повторов нет - выполнение сразу пропускается.
+func (s *Service) SetSchedulePolicy(retries int, interval time.Duration) {
+	s.scheduleRetries = retries
+	s.scheduleRetryInterval = interval
+}
+
+//ScheduleFavorite - назначает (или заменяет) расписание автоматических платежей по избранному
+func (s *Service) ScheduleFavorite(favoriteID string, spec string) (*types.Schedule, error) {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+	if favorite.Amount == 0 {
+		return nil, ErrFavoriteAmountRequired
+	}
+	cron, err := parseSchedule(spec)
+	if err != nil {
+		return nil, err
+	}
+	next := cron.Next(s.now())
+	if next.IsZero() {
+		return nil, ErrInvalidSchedule
+	}
+
+	schedule := &types.Schedule{FavoriteID: favorite.ID, Spec: spec, NextRun: next}
+	for i, saved := range s.schedules {
+		if saved.FavoriteID == favorite.ID {
+			s.schedules[i] = schedule
+			return schedule, nil
+		}
+	}
+	s.schedules = append(s.schedules, schedule)
+	return schedule, nil
+}
+
+//FindSchedule - расписание избранного
+func (s *Service) FindSchedule(favoriteID string) (*types.Schedule, error) {
+	for _, schedule := range s.schedules {
+		if schedule.FavoriteID == favoriteID {
+			return schedule, nil
+		}
+	}
+	return nil, ErrScheduleNotFound
+}
+
+//Unschedule - отменяет расписание избранного
+func (s *Service) Unschedule(favoriteID string) error {
+	for i, schedule := range s.schedules {
+		if schedule.FavoriteID == favoriteID {
+			s.schedules = append(s.schedules[:i], s.schedules[i+1:]...)
+			return nil
+		}
+	}
+	return ErrScheduleNotFound
+}
+
+//ScheduleExecutions - история выполнений по расписанию избранного
+func (s *Service) ScheduleExecutions(favoriteID string) []types.Execution {
+	executions := []types.Execution{}
+	for _, execution := range s.executions {
+		if execution.FavoriteID == favoriteID {
+			executions = append(executions, *execution)
+		}
+	}
+	return executions
+}
+
+//RunDueSchedules - выполняет все платежи, время которых наступило. Пропущенные за время простоя
+//выполнения не накапливаются: платёж делается один раз, а следующий назначается после текущего момента.
+func (s *Service) RunDueSchedules() []types.Execution {
+	now := s.now()
+	executions := []types.Execution{}
+	for _, schedule := range s.schedules {
+		if schedule.NextRun.After(now) {
+			continue
+		}
+
+		execution := types.Execution{FavoriteID: schedule.FavoriteID, ScheduledAt: schedule.NextRun, ExecutedAt: now}
+		payment, err := s.PayFromFavorite(schedule.FavoriteID)
+		switch {
+		case err == nil:
+			execution.Status = types.ExecutionStatusOk
+			execution.PaymentID = payment.ID
+		case err == ErrNotEnoughBalance && schedule.Attempts < s.scheduleRetries:
+			execution.Status = types.ExecutionStatusRetry
+		case err == ErrNotEnoughBalance:
+			execution.Status = types.ExecutionStatusSkipped
+		default:
+			execution.Status = types.ExecutionStatusFail
+		}
+		if err != nil {
+			execution.Error = err.Error()
+		}
+
+		if execution.Status == types.ExecutionStatusRetry {
+			schedule.Attempts++
+			schedule.NextRun = now.Add(s.scheduleRetryInterval)
+		} else {
+			schedule.Attempts = 0
+			cron, err := parseSchedule(schedule.Spec)
+			if err == nil {
+				schedule.NextRun = cron.Next(now)
+			}
+		}
+
+		s.executions = append(s.executions, &execution)
+		executions = append(executions, execution)
+	}
+	return executions
+}
+
+//RunScheduler - выполняет наступившие платежи каждые interval, пока не отменён ctx.
+//Service не рассчитан на параллельные вызовы, поэтому пока работает планировщик,
+//остальные операции нужно выполнять из той же горутины или под общей блокировкой.
+func (s *Service) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RunDueSchedules()
+		}
+	}
+}
+
+//dumpText - убирает из текста разделители формата дампа
+var dumpText = strings.NewReplacer(";", ",", "|", "/")
+
+//exportSchedules - сохраняет расписания и историю их выполнения
+func (s *Service) exportSchedules(dir string) error {
+	records := make([]string, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		records = append(records, schedule.FavoriteID+";"+schedule.Spec+";"+
+			encodeTime(schedule.NextRun)+";"+strconv.Itoa(schedule.Attempts))
+	}
+	err := writeDump(dir, "schedules.dump", records)
+	if err != nil {
+		return err
+	}
+
+	records = make([]string, 0, len(s.executions))
+	for _, execution := range s.executions {
+		records = append(records, execution.FavoriteID+";"+encodeTime(execution.ScheduledAt)+";"+
+			encodeTime(execution.ExecutedAt)+";"+string(execution.Status)+";"+
+			execution.PaymentID+";"+dumpText.Replace(execution.Error))
+	}
+	return writeDump(dir, "executions.dump", records)
+}
+
+//importSchedules - загружает расписания и историю их выполнения
+func (s *Service) importSchedules(dir string) error {
+	values, err := readDump(dir, "schedules.dump")
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		if len(value) < 4 {
+			return ErrInvalidDump
+		}
+		nextRun, err := decodeTime(value[2])
+		if err != nil {
+			return err
+		}
+		attempts, err := strconv.Atoi(value[3])
+		if err != nil {
+			return err
+		}
+		s.schedules = append(s.schedules, &types.Schedule{
+			FavoriteID: value[0],
+			Spec:       value[1],
+			NextRun:    nextRun,
+			Attempts:   attempts,
+		})
+	}
+
+	values, err = readDump(dir, "executions.dump")
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		if len(value) < 6 {
+			return ErrInvalidDump
+		}
+		scheduledAt, err := decodeTime(value[1])
+		if err != nil {
+			return err
+		}
+		executedAt, err := decodeTime(value[2])
+		if err != nil {
+			return err
+		}
+		s.executions = append(s.executions, &types.Execution{
+			FavoriteID:  value[0],
+			ScheduledAt: scheduledAt,
+			ExecutedAt:  executedAt,
+			Status:      types.ExecutionStatus(value[3]),
+			PaymentID:   value[4],
+			Error:       value[5],
+		})
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func TestParseSchedule_next(t *testing.T) {
+	//пятница, 20 ноября 2020, 14:00
+	after := time.Date(2020, 11, 20, 14, 0, 0, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"daily 09:30", time.Date(2020, 11, 21, 9, 30, 0, 0, time.UTC)},
+		{"daily 14:01", time.Date(2020, 11, 20, 14, 1, 0, 0, time.UTC)},
+		{"weekly mon 08:00", time.Date(2020, 11, 23, 8, 0, 0, 0, time.UTC)},
+		{"weekly fri 14:00", time.Date(2020, 11, 27, 14, 0, 0, 0, time.UTC)},
+		{"monthly 1 10:00", time.Date(2020, 12, 1, 10, 0, 0, 0, time.UTC)},
+		{"monthly 31 10:00", time.Date(2020, 12, 31, 10, 0, 0, 0, time.UTC)},
+		{"cron */15 * * * *", time.Date(2020, 11, 20, 14, 15, 0, 0, time.UTC)},
+		{"cron 0 9-18/3 * * 1-5", time.Date(2020, 11, 20, 15, 0, 0, 0, time.UTC)},
+		{"cron 0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		schedule, err := parseSchedule(test.spec)
+		if err != nil {
+			t.Errorf("parseSchedule(%q): error = %v", test.spec, err)
+			continue
+		}
+		got := schedule.Next(after)
+		if !got.Equal(test.want) {
+			t.Errorf("Next(%q): want => %v got => %v", test.spec, test.want, got)
+		}
+	}
+}
+
+func TestParseSchedule_invalid(t *testing.T) {
+	specs := []string{"", "hourly", "daily", "daily 25:00", "weekly xyz 09:00", "monthly 32 09:00",
+		"cron * * *", "cron 60 * * * *", "cron */0 * * * *", "cron 5-1 * * * *"}
+	for _, spec := range specs {
+		_, err := parseSchedule(spec)
+		if err != ErrInvalidSchedule {
+			t.Errorf("parseSchedule(%q): must return ErrInvalidSchedule, returned = %v", spec, err)
+		}
+	}
+}
+
+func newTestScheduleService(t *testing.T, balance types.Money) (*Service, *testClock, *types.Account, *types.Favorite) {
+	svc, clock, account := newTestServiceUserWithClock(t, balance)
+	payment, err := svc.Pay(account.ID, 100_00, "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	favorite, err := svc.FavoritePayment(payment.ID, "Tcell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc.Service, clock, account, favorite
+}
+
+func TestService_RunDueSchedules_success(t *testing.T) {
+	svc, clock, account, favorite := newTestScheduleService(t, 1_000_00)
+
+	schedule, err := svc.ScheduleFavorite(favorite.ID, "daily 09:00")
+	if err != nil {
+		t.Fatalf("ScheduleFavorite(): error = %v", err)
+	}
+	if executions := svc.RunDueSchedules(); len(executions) != 0 {
+		t.Fatalf("RunDueSchedules(): nothing is due yet, got = %v", executions)
+	}
+
+	clock.Add(19 * time.Hour)
+	executions := svc.RunDueSchedules()
+	if len(executions) != 1 || executions[0].Status != types.ExecutionStatusOk || executions[0].PaymentID == "" {
+		t.Fatalf("RunDueSchedules(): wrong executions = %v", executions)
+	}
+	if account.Balance != 800_00 {
+		t.Errorf("RunDueSchedules(): wrong balance = %v", account.Balance)
+	}
+	want := time.Date(2020, 11, 22, 9, 0, 0, 0, time.UTC)
+	if !schedule.NextRun.Equal(want) {
+		t.Errorf("RunDueSchedules(): wrong next run, want => %v got => %v", want, schedule.NextRun)
+	}
+
+	//простой в несколько дней - один платёж, а не несколько
+	clock.Add(72 * time.Hour)
+	executions = svc.RunDueSchedules()
+	if len(executions) != 1 {
+		t.Errorf("RunDueSchedules(): missed runs must not pile up, got = %v", executions)
+	}
+	if history := svc.ScheduleExecutions(favorite.ID); len(history) != 2 {
+		t.Errorf("ScheduleExecutions(): wrong history = %v", history)
+	}
+}
+
+func TestService_RunDueSchedules_retryThenSkip(t *testing.T) {
+	svc, clock, account, favorite := newTestScheduleService(t, 150_00)
+	svc.SetSchedulePolicy(2, time.Hour)
+
+	_, err := svc.ScheduleFavorite(favorite.ID, "weekly mon 10:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//понедельник 23.11 10:00 - не хватает баланса
+	clock.Add(68 * time.Hour)
+	statuses := []types.ExecutionStatus{}
+	for i := 0; i < 3; i++ {
+		for _, execution := range svc.RunDueSchedules() {
+			statuses = append(statuses, execution.Status)
+		}
+		clock.Add(time.Hour)
+	}
+	want := []types.ExecutionStatus{types.ExecutionStatusRetry, types.ExecutionStatusRetry, types.ExecutionStatusSkipped}
+	if !reflect.DeepEqual(want, statuses) {
+		t.Fatalf("RunDueSchedules(): want => %v got => %v", want, statuses)
+	}
+
+	schedule, err := svc.FindSchedule(favorite.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := time.Date(2020, 11, 30, 10, 0, 0, 0, time.UTC)
+	if schedule.Attempts != 0 || !schedule.NextRun.Equal(next) {
+		t.Errorf("RunDueSchedules(): after skip want next => %v got => %+v", next, schedule)
+	}
+
+	//баланс пополнили - следующий платёж проходит
+	err = svc.Deposit(account.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(7 * 24 * time.Hour)
+	executions := svc.RunDueSchedules()
+	if len(executions) != 1 || executions[0].Status != types.ExecutionStatusOk {
+		t.Errorf("RunDueSchedules(): wrong executions = %v", executions)
+	}
+}
+
+func TestService_ScheduleFavorite_fail(t *testing.T) {
+	svc, _, _, favorite := newTestScheduleService(t, 1_000_00)
+
+	_, err := svc.ScheduleFavorite("unknown", "daily 09:00")
+	if err != ErrFavoriteNotFound {
+		t.Errorf("ScheduleFavorite(): must return ErrFavoriteNotFound, returned = %v", err)
+	}
+	_, err = svc.ScheduleFavorite(favorite.ID, "daily 9")
+	if err != ErrInvalidSchedule {
+		t.Errorf("ScheduleFavorite(): must return ErrInvalidSchedule, returned = %v", err)
+	}
+	err = svc.Unschedule(favorite.ID)
+	if err != ErrScheduleNotFound {
+		t.Errorf("Unschedule(): must return ErrScheduleNotFound, returned = %v", err)
+	}
+}
+
+func TestService_Schedules_exportImport(t *testing.T) {
+	svc, clock, _, favorite := newTestScheduleService(t, 150_00)
+	svc.SetSchedulePolicy(1, time.Hour)
+	schedule, err := svc.ScheduleFavorite(favorite.ID, "cron 0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(19 * time.Hour)
+	svc.RunDueSchedules()
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := imported.FindSchedule(favorite.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Spec != schedule.Spec || got.Attempts != schedule.Attempts || !got.NextRun.Equal(schedule.NextRun) {
+		t.Errorf("Import(): want => %+v got => %+v", schedule, got)
+	}
+	want := svc.ScheduleExecutions(favorite.ID)
+	history := imported.ScheduleExecutions(favorite.ID)
+	if len(history) != len(want) {
+		t.Fatalf("Import(): want => %v got => %v", want, history)
+	}
+	for i := range want {
+		if history[i].Status != want[i].Status || history[i].Error != want[i].Error ||
+			!history[i].ScheduledAt.Equal(want[i].ScheduledAt) || !history[i].ExecutedAt.Equal(want[i].ExecutedAt) {
+			t.Errorf("Import(): want => %v got => %v", want[i], history[i])
+		}
+	}
+}
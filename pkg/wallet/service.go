@@ -1,7 +1,7 @@
 package wallet
 
 import (
-	"sync"
+	"context"
 	"strings"
 	"io"
 	"strconv"
@@ -9,6 +9,8 @@ import (
 	"log"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/Shahlojon/wallet/pkg/types"
 	"github.com/google/uuid"
@@ -21,12 +23,44 @@ var ErrAccountNotFound = errors.New("account not found")
 var ErrNotEnoughBalance = errors.New("balance is null")
 var ErrPaymentNotFound = errors.New("payment not found")
 var ErrFileNotFound = errors.New("file not found")
+var ErrInvalidDump = errors.New("invalid dump record")
+var ErrPaymentNotRepeatable = errors.New("payment can't be repeated")
 
 type Service struct {
-	nextAccountID int64 //Для генерации уникального номера аккаунта
-	accounts      []*types.Account
-	payments      []*types.Payment
-	favorites     []*types.Favorite
+	nextAccountID  int64 //Для генерации уникального номера аккаунта
+	accounts       []*types.Account
+	payments       []*types.Payment
+	deposits       []*types.Deposit
+	favorites      []*types.Favorite
+	journal        []JournalEntry   //Журнал операций для восстановления состояния на момент времени
+	journalFlushed int              //Сколько записей журнала уже сохранено на диск
+	clock          func() time.Time //Источник времени, по умолчанию time.Now
+	progress       ProgressFunc     //Получатель отчётов о прогрессе долгих операций
+	limits         []*types.Limits
+	holds          []*types.Payment //Авторизованные платежи, ожидающие Capture или Void
+	holdTTL        time.Duration    //Срок жизни резерва, 0 - DefaultHoldTTL
+
+	schedules             []*types.Schedule
+	executions            []*types.Execution
+	scheduleRetries       int           //Сколько раз повторять платёж по расписанию, если не хватило баланса
+	scheduleRetryInterval time.Duration //Через сколько повторять
+
+	tierLimits map[types.VerificationTier]types.TierLimits
+
+	fees             []*types.FeeRule
+	keepFeesOnReject bool //Не возвращать комиссию при Reject
+	cashback         []*types.CashbackRule
+
+	categories   []*types.Category //Справочник категорий платежей
+	categoryMode CategoryMode
+
+	nextMerchantID int64
+	merchants      []*types.Merchant
+
+	eventsMu    sync.Mutex //Защищает подписчиков и outbox от горутин асинхронных подписчиков
+	eventsWG    sync.WaitGroup
+	subscribers []*subscriber
+	outbox      []*outboxEvent //События, ещё не доставленные всем подписчикам
 }
 
 type Error string
@@ -40,19 +74,43 @@ func (e Error) Error() string {
 	return string(e)
 }
 
+//SetClock - задаёт источник времени для сервиса (например, фиксированные часы в тестах)
+func (s *Service) SetClock(clock func() time.Time) {
+	s.clock = clock
+}
+
+//now - текущее время сервиса
+func (s *Service) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock()
+}
+
 func (s *Service) RegisterAccount(phone types.Phone) (*types.Account, error) {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
 	for _, account := range s.accounts {
 		if account.Phone == phone {
 			return nil, ErrPhoneRegistered
 		}
 	}
 	s.nextAccountID++
+	now := s.now()
 	account := &types.Account{
-		ID:      s.nextAccountID,
-		Phone:   phone,
-		Balance: 0,
+		ID:        s.nextAccountID,
+		Phone:     phone,
+		Balance:   0,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Status:    types.AccountStatusActive,
 	}
 	s.accounts = append(s.accounts, account)
+	s.recordAccount(OperationRegister, account)
+	copyAccount := *account
+	s.publish(EventAccountRegistered, account, Event{Account: &copyAccount})
 
 	return account, nil
 }
@@ -73,12 +131,41 @@ func (s *Service) Deposit(accountID int64, amount types.Money) error {
 	if account == nil {
 		return ErrAccountNotFound
 	}
+	err := checkActive(account)
+	if err != nil {
+		return err
+	}
+	err = s.checkTier(account, amount, 0)
+	if err != nil {
+		return err
+	}
 
+	//отрицательный баланс (овердрафт) погашается в первую очередь
+	now := s.now()
 	account.Balance += amount
+	account.UpdatedAt = now
+	s.addTurnover(account, amount)
+	s.updateOverdraft(account)
+	deposit := &types.Deposit{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Amount:    amount,
+		CreatedAt: now,
+	}
+	s.deposits = append(s.deposits, deposit)
+	s.recordAccount(OperationDeposit, account)
+	s.recordDeposit(OperationDeposit, deposit)
+	copyDeposit := *deposit
+	s.publish(EventDeposited, account, Event{Deposit: &copyDeposit})
 	return nil
 }
 
 func (s *Service) Pay(accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	return s.pay(accountID, amount, category, 0)
+}
+
+//pay - списывает платёж со счёта; merchantID - продавец-получатель, 0 - без продавца
+func (s *Service) pay(accountID int64, amount types.Money, category types.PaymentCategory, merchantID int64) (*types.Payment, error) {
 	if amount <= 0 {
 		return nil, ErrAmountMustBePositive
 	}
@@ -93,21 +180,52 @@ func (s *Service) Pay(accountID int64, amount types.Money, category types.Paymen
 	if account == nil {
 		return nil, ErrAccountNotFound
 	}
+	err := checkActive(account)
+	if err != nil {
+		return nil, err
+	}
+	category, err = s.resolveCategory(category)
+	if err != nil {
+		return nil, err
+	}
 
-	if account.Balance < amount {
+	s.expireHolds()
+	fee := s.fee(account, amount, category)
+	if account.Available()+account.Overdraft < amount+fee {
 		return nil, ErrNotEnoughBalance
 	}
 
-	account.Balance -= amount
+	err = s.checkLimits(accountID, amount, category)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkTier(account, 0, amount+fee)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	account.Balance -= amount + fee
+	account.UpdatedAt = now
+	s.addTurnover(account, amount+fee)
+	s.updateOverdraft(account)
 	paymentID := uuid.New().String()
 	payment := &types.Payment{
-		ID:        paymentID,
-		AccountID: accountID,
-		Amount:    amount,
-		Category:  category,
-		Status:    types.PaymentStatusInProgress,
+		ID:         paymentID,
+		AccountID:  accountID,
+		Amount:     amount,
+		Category:   category,
+		Status:     types.PaymentStatusInProgress,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		MerchantID: merchantID,
 	}
 	s.payments = append(s.payments, payment)
+	s.recordAccount(OperationPay, account)
+	s.recordPayment(OperationPay, payment)
+	s.chargeFee(payment, fee, OperationPay)
+	copyPayment := *payment
+	s.publish(EventPaymentCreated, account, Event{Payment: &copyPayment})
 	return payment, nil
 }
 
@@ -133,15 +251,36 @@ func (s *Service) Reject(paymentID string) error {
 	if err != nil {
 		return err
 	}
+	//по авторизованному платежу ещё ничего не списано - достаточно снять резерв
+	if payment.Status == types.PaymentStatusAuthorized {
+		return s.Void(paymentID)
+	}
+	//снятый резерв (VOID) ничего не списывал, а отменённый (FAIL) уже возвращён;
+	//комиссия возвращается только вместе со своим платежом, по правилу SetKeepFeesOnReject
+	if !refundable(payment) {
+		return ErrPaymentNotRefundable
+	}
 	
 	account, err := s.FindAccountByID(payment.AccountID)
 
 	if err != nil {
 		return err
 	}
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
 
+	now := s.now()
 	payment.Status = types.PaymentStatusFail
-	account.Balance += payment.Amount
+	payment.UpdatedAt = now
+	//уже возвращённая частичными возвратами сумма второй раз не возвращается
+	account.Balance += payment.Amount - s.refunded(payment.ID) + s.rejectFee(payment) - s.clawbackCashback(payment)
+	account.UpdatedAt = now
+	s.updateOverdraft(account)
+	s.recordAccount(OperationReject, account)
+	s.recordPayment(OperationReject, payment)
+	copyPayment := *payment
+	s.publish(EventPaymentRejected, account, Event{Payment: &copyPayment})
 	return nil
 }
 
@@ -174,7 +313,7 @@ type testAccountUser struct {
 }
 
 var defaultTestAccountUser = testAccountUser{
-	phone:   "+99200000001",
+	phone:   "+992000000001",
 	balance: 10_000_00,
 	payments: []struct {
 		amount   types.Money
@@ -191,10 +330,12 @@ func (s *testServiceUser) addAccountUser(data testAccountUser) (*types.Account,
 		return nil, nil, fmt.Errorf("can't register account, error = %v", err)
 	}
 
-	//пополняем его счет
-	err = s.Deposit(account.ID, data.balance)
-	if err != nil {
-		return nil, nil, fmt.Errorf("can't deposity account, error = %v", err)
+	//пополняем его счет, если задан баланс
+	if data.balance != 0 {
+		err = s.Deposit(account.ID, data.balance)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't deposity account, error = %v", err)
+		}
 	}
 
 	//выпоняем платежи
@@ -218,8 +359,18 @@ func (s *Service) Repeat(paymentID string) (*types.Payment, error){
 	if err!=nil {
 		return nil, err
 	}
+	//повторить можно только обычный платёж: не перевод, не связанную запись
+	//(возврат, комиссию, кешбэк) и не резерв
+	switch pay.Status {
+	case types.PaymentStatusOk, types.PaymentStatusInProgress, types.PaymentStatusFail:
+	default:
+		return nil, ErrPaymentNotRepeatable
+	}
+	if pay.ToAccountID != 0 || pay.ParentID != "" {
+		return nil, ErrPaymentNotRepeatable
+	}
 
-	payment, err :=s.Pay(pay.AccountID, pay.Amount, pay.Category)
+	payment, err :=s.pay(pay.AccountID, pay.Amount, pay.Category, pay.MerchantID)
 	if err!=nil {
 		return nil, err
 	}
@@ -233,40 +384,35 @@ func (s *Service) FavoritePayment(paymentID string, name string) (*types.Favorit
 		return nil, err
 	}
 
-	favoriteID := uuid.New().String()
-	favorite := &types.Favorite{
-		ID:        favoriteID,
-		AccountID: pay.AccountID,
-		Amount:    pay.Amount,
-		Category:  pay.Category,
-		Name:      name,
-	}
-
-	s.favorites = append(s.favorites, favorite)
-	return favorite, err
-
+	return s.CreateFavorite(pay.AccountID, name, pay.Category, pay.Amount)
 }
 
-//PayFromFavorite
+//PayFromFavorite - платит по избранному на сумму избранного.
+//Если у избранного суммы нет, возвращается ErrFavoriteAmountRequired.
 func (s *Service) PayFromFavorite(favoriteID string) (*types.Payment, error) {
-	var favorite *types.Favorite
-	for _, favorites := range s.favorites {
-		if favorites.ID == favoriteID {
-			favorite = favorites
-			break
-		}
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
 	}
-
-	if favorite == nil {
-		return nil, ErrFavoriteNotFound
+	if favorite.Amount == 0 {
+		return nil, ErrFavoriteAmountRequired
 	}
 
-	pay, err := s.Pay(favorite.AccountID, favorite.Amount, favorite.Category)
+	return s.Pay(favorite.AccountID, favorite.Amount, favorite.Category)
+}
+
+//PayFromFavoriteAmount - платит по избранному на сумму amount (например, для коммунальных
+//платежей, где она каждый месяц разная). Сумма избранного не используется и не меняется.
+func (s *Service) PayFromFavoriteAmount(favoriteID string, amount types.Money) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	favorite, err := s.FindFavoriteByID(favoriteID)
 	if err != nil {
 		return nil, err
 	}
 
-	return pay, nil
+	return s.Pay(favorite.AccountID, amount, favorite.Category)
 }
 
 //ExportToFile - экспортирует все аккаунты
@@ -355,7 +501,10 @@ func (s *Service) ImportFromFile(path string) error {
 			Balance: types.Money(balance),
 		}
 
-		s.accounts = append(s.accounts, editAccount)
+		err = s.addImportedAccount(editAccount)
+		if err != nil {
+			return err
+		}
 		log.Print(account)
 	}
 	return nil
@@ -363,6 +512,9 @@ func (s *Service) ImportFromFile(path string) error {
 
 //Export(dir string) error
 func (s *Service) Export(dir string) error {
+	tracker := s.track("export", len(s.accounts)+len(s.payments)+len(s.favorites))
+	defer tracker.done()
+
 	lenAccounts := len(s.accounts)
 
 	if lenAccounts!=0 {
@@ -380,13 +532,8 @@ func (s *Service) Export(dir string) error {
 		}()
 		data := ""
 		for _, account := range s.accounts {
-			id := strconv.Itoa(int(account.ID))+";"
-			phone:=string(account.Phone)+";"
-			balance := strconv.Itoa(int(account.Balance))
-
-			data +=id
-			data += phone 
-			data +=balance+"|"
+			data += encodeAccount(account) + "|"
+			tracker.add(1)
 		}
 
 		_, err = file.Write([]byte(data))
@@ -414,17 +561,8 @@ func (s *Service) Export(dir string) error {
 		}()
 		data := ""
 		for _, payment := range s.payments {
-			idPayment := string(payment.ID)+";"
-			idPaymnetAccountId := strconv.Itoa(int(payment.AccountID))+";"
-			amountPayment :=strconv.Itoa(int(payment.Amount))+";"
-			categoryPayment :=string(payment.Category)+";"
-			statusPayment := string(payment.Status)
-
-			data +=idPayment
-			data += idPaymnetAccountId 
-			data+=amountPayment
-			data+=categoryPayment
-			data +=statusPayment+"|"
+			data += encodePayment(payment) + "|"
+			tracker.add(1)
 		}
 
 		_, err = file.Write([]byte(data))
@@ -436,6 +574,15 @@ func (s *Service) Export(dir string) error {
 
 	lenFavorites := len(s.favorites)
 
+	if lenFavorites==0 {
+		//все избранные могли удалить - старый дамп больше не актуален
+		err := os.Remove(dir+"/favorites.dump")
+		if err != nil && !os.IsNotExist(err) {
+			log.Print(err)
+			return ErrFileNotFound
+		}
+	}
+
 	if lenFavorites!=0 {
 		fileDir := dir+"/favorites.dump"
 		file, err :=os.Create(fileDir)	
@@ -451,17 +598,8 @@ func (s *Service) Export(dir string) error {
 		}()
 		data := ""
 		for _, favorite := range s.favorites {
-			idFavorite := string(favorite.ID)+";"
-			idFavoriteAccountId := strconv.Itoa(int(favorite.AccountID))+";"
-			nameFavorite := string(favorite.Name)+";"
-			amountFavorite :=strconv.Itoa(int(favorite.Amount))+";"
-			categoryFavorite :=string(favorite.Category)
-
-			data +=idFavorite
-			data+=idFavoriteAccountId
-			data += nameFavorite 
-			data+=amountFavorite
-			data +=categoryFavorite+"|"
+			data += encodeFavorite(favorite) + "|"
+			tracker.add(1)
 		}
 		_, err = file.Write([]byte(data))
 		if err!=nil {
@@ -469,12 +607,53 @@ func (s *Service) Export(dir string) error {
 			return ErrFileNotFound
 		}
 	}
+
+	err := s.exportLimits(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportSchedules(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportTierLimits(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportFees(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportCashback(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportCategories(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportMerchants(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportDeposits(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportOutbox(dir)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
 
 // Import(dir string) error
 func (s *Service) Import(dir string) error {
+	//количество записей становится известно по мере чтения файлов
+	tracker := s.track("import", 0)
+	defer tracker.done()
+
 	dirAccount := dir + "/accounts.dump"
 	file, err := os.Open(dirAccount)
 
@@ -513,28 +692,23 @@ func (s *Service) Import(dir string) error {
 		// if accounts == nil {
 		// 	return ErrAccountNotFound
 		// }
+		tracker.expect(len(accounts))
 
 		for _, account := range accounts {
 
 			value := strings.Split(account, ";")
 
-			id,err := strconv.Atoi(value[0])
+			editAccount, err := decodeAccount(value)
 			if err!=nil {
 				return err
 			}
-			phone :=types.Phone(value[1])
-			balance, err := strconv.Atoi(value[2])
-			if err!=nil {
-				return err
-			}
-			editAccount := &types.Account {
-				ID: int64(id),
-				Phone: phone,
-				Balance: types.Money(balance),
-			}
 			//log.Print(editAccount, " read")
 
-			s.accounts = append(s.accounts, editAccount)
+			err = s.addImportedAccount(editAccount)
+			if err != nil {
+				return err
+			}
+			tracker.add(1)
 		}
 	}
 
@@ -572,33 +746,21 @@ func (s *Service) Import(dir string) error {
 		
 		payments :=strings.Split(data, "|")
 		payments = payments[:len(payments)-1]
+		tracker.expect(len(payments))
 		//log.Print(favorites, " fav")
 		for _, payment := range payments {
 			
 			value := strings.Split(payment, ";")
-			idPayment := string(value[0])
-			
-			accountIdPeyment, err :=strconv.Atoi(value[1])
-			if err!=nil {
-				return err
-			}
-
-			amountPayment, err:=strconv.Atoi(value[2])
+			newPayment, err := decodePayment(value)
 			if err!=nil {
 				return err
 			}
-			categoryPayment:=types.PaymentCategory(value[3])
-			
-			statusPayment :=types.PaymentStatus(value[4])
-			newPayment := &types.Payment {
-				ID: idPayment,
-				AccountID: int64(accountIdPeyment),
-				Amount: types.Money(amountPayment),
-				Category: categoryPayment,
-				Status: statusPayment,
-			}
 
 			s.payments = append(s.payments, newPayment)
+			if newPayment.Status == types.PaymentStatusAuthorized {
+				s.holds = append(s.holds, newPayment)
+			}
+			tracker.add(1)
 			//log.Print(payment)
 			
 		}
@@ -638,45 +800,83 @@ func (s *Service) Import(dir string) error {
 		//log.Print(dirfavorite, " fav ", data)
 		favorites :=strings.Split(data, "|")
 		favorites = favorites[:len(favorites)-1]
+		tracker.expect(len(favorites))
 
 		for _, favorite := range favorites {
 			
 			valueFavorite := strings.Split(favorite, ";")
-			idFavorite := string(valueFavorite[0])
-			accountIdFavorite, err :=strconv.Atoi(valueFavorite[1])
-			if err!=nil {
-				return err
-			}
-			nameFavorite :=string(valueFavorite[2])
-
-			amountFavorite, err:=strconv.Atoi(valueFavorite[3])
+			newFavorite, err := decodeFavorite(valueFavorite)
 			if err!=nil {
 				return err
 			}
-			categoryPayment:=types.PaymentCategory(valueFavorite[4])
-			
-			newFavorite := &types.Favorite {
-				ID: idFavorite,
-				AccountID: int64(accountIdFavorite),
-				Name: nameFavorite,
-				Amount: types.Money(amountFavorite),
-				Category: categoryPayment,
-			}
 
 			s.favorites = append(s.favorites, newFavorite)
+			tracker.add(1)
 			//log.Print(favorite)
 		}
 	}
+
+	err = s.importLimits(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importSchedules(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importTierLimits(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importFees(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importCashback(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importCategories(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importMerchants(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importDeposits(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importOutbox(dir)
+	if err != nil {
+		return err
+	}
 	
 	return nil
 }
 
-//ExportAccountHistory
+//ExportAccountHistory - платежи аккаунта; возвраты идут сразу после платежа, к которому относятся
 func (s *Service) ExportAccountHistory(accountID int64) ([]types.Payment, error){
 	var paymentFound []types.Payment
 
+	//связанные записи (возвраты, комиссии, кешбэк) идут сразу после исходного платежа
+	linked := map[string][]types.Payment{}
 	for _, payment := range s.payments {
-		if payment.AccountID == accountID {
+		if payment.AccountID == accountID && payment.ParentID != "" {
+			linked[payment.ParentID] = append(linked[payment.ParentID], *payment)
+		}
+	}
+	for _, payment := range s.payments {
+		if payment.AccountID == accountID && payment.ParentID == "" {
+			paymentFound = append(paymentFound, *payment)
+			paymentFound = append(paymentFound, linked[payment.ID]...)
+			delete(linked, payment.ID)
+		}
+	}
+	//связанные записи, исходного платежа которых нет (например, в неполном дампе)
+	for _, payment := range s.payments {
+		if _, ok := linked[payment.ParentID]; ok && payment.AccountID == accountID && payment.ParentID != "" {
 			paymentFound = append(paymentFound, *payment)
 		}
 	}
@@ -688,6 +888,9 @@ func (s *Service) ExportAccountHistory(accountID int64) ([]types.Payment, error)
 
 //HistoryToFiles
 func (s *Service) HistoryToFiles(payments []types.Payment, dir string, records int) error {
+	tracker := s.track("history", len(payments))
+	defer tracker.done()
+
 	if len(payments) > 0 {
 		if len(payments) <= records {
 			file, _ := os.OpenFile(dir+"/payments.dump", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
@@ -711,6 +914,7 @@ func (s *Service) HistoryToFiles(payments []types.Payment, dir string, records i
 				str+=amountPayment
 				str+=categoryPayment
 				str +=statusPayment+"\n"
+				tracker.add(1)
 			}
 			_, err := file.WriteString(str)
 			if err!=nil {
@@ -731,6 +935,7 @@ func (s *Service) HistoryToFiles(payments []types.Payment, dir string, records i
 				if err!=nil {
 					log.Print(err)
 				}
+				tracker.add(1)
 				if k == records{
 					str=""
 					t++
@@ -824,230 +1029,36 @@ func (s *Service) HistoryToFiles(payments []types.Payment, dir string, records i
 // 	return nil
 // }
 
-//SumPayments ...
+//SumPayments - сумма всех неотменённых платежей, посчитанная в goroutines горутинах
+//(0 или отрицательное значение - по числу процессоров)
 func (s *Service) SumPayments(goroutines int) types.Money {
-	wg := sync.WaitGroup{}
-	mu := sync.Mutex{}
-	sum := int64(0)
-	kol := 0
-	i := 0
-	if goroutines == 0 {
-		kol = len(s.payments)
-	} else {
-		kol = int(len(s.payments) / goroutines)
-	}
-	for i = 0; i < goroutines-1; i++ {
-		wg.Add(1)
-		go func(index int) {
-			defer wg.Done()
-			val := int64(0)
-			payments := s.payments[index*kol : (index+1)*kol]
-			for _, payment := range payments {
-				val += int64(payment.Amount)
-			}
-			mu.Lock()
-			sum += val
-			mu.Unlock()
-
-		}(i)
-	}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		val := int64(0)
-		payments := s.payments[i*kol:]
-		for _, payment := range payments {
-			val += int64(payment.Amount)
-		}
-		mu.Lock()
-		sum += val
-		mu.Unlock()
-
-	}()
-	wg.Wait()
-	return types.Money(sum)
+	options := SpentOptions
+	options.Workers = goroutines
+	sum, _ := s.SumPaymentsContext(context.Background(), options)
+	return sum
 }
 
-//FilterPayments
-func (s Service) FilterPayments(accountID int64, goroutines int) (newPayment []types.Payment, err error) {
-	if goroutines < 2 {
-		for _, value := range s.payments {
-			if value.AccountID == accountID {
-				newPayment = append(newPayment, *value)
-			}
-		}
-		if newPayment == nil {
-			return nil, ErrAccountNotFound
-		}
-		return
-	}
-	wg := sync.WaitGroup{}
-	mutex := sync.Mutex{}
-	max := 0
-	count := len(s.payments) / goroutines
-	for i := 1; i < goroutines; i++ {
-		max += count
-		wg.Add(1)
-		go func(val int) {
-			defer wg.Done()
-			sum := []types.Payment{}
-			for _, value := range s.payments[val-count : val] {
-				if value.AccountID == accountID {
-					sum = append(sum, *value)
-				}
-			}
-			mutex.Lock()
-			newPayment = append(newPayment, sum...)
-			mutex.Unlock()
-		}(max)
-	}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		sum := []types.Payment{}
-		for _, value := range s.payments[max:] {
-			if value.AccountID == accountID {
-				sum = append(sum, *value)
-			}
-		}
-		mutex.Lock()
-		newPayment = append(newPayment, sum...)
-		mutex.Unlock()
-	}()
-	wg.Wait()
-	if newPayment == nil {
-		return nil, ErrAccountNotFound
+//FilterPayments - платежи аккаунта в исходном порядке. Если у аккаунта нет платежей,
+//возвращается пустой результат; ErrAccountNotFound - только для несуществующего аккаунта.
+func (s *Service) FilterPayments(accountID int64, goroutines int) ([]types.Payment, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
 	}
-	return
-}
 
-//FilterPaymentsByFn
-func (s Service) FilterPaymentsByFn(filter func(payment types.Payment) bool, goroutines int, ) (newPayment []types.Payment, err error) {
-	if goroutines < 2 {
-		for _, value := range s.payments {
-			if filter(*value) {
-				newPayment = append(newPayment, *value)
-			}
-		}
-		if newPayment == nil {
-			return nil, ErrAccountNotFound
-		}
-		return
-	}
-	wg := sync.WaitGroup{}
-	mutex := sync.Mutex{}
-	max := 0
-	count := len(s.payments) / goroutines
-	for i := 1; i < goroutines; i++ {
-		max += count
-		wg.Add(1)
-		go func(val int) {
-			defer wg.Done()
-			sum := []types.Payment{}
-			for _, value := range s.payments[val-count : val] {
-				if filter(*value) {
-					sum = append(sum, *value)
-				}
-			}
-			mutex.Lock()
-			newPayment = append(newPayment, sum...)
-			mutex.Unlock()
-		}(max)
-	}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		sum := []types.Payment{}
-		for _, value := range s.payments[max:] {
-			if filter(*value) {
-				sum = append(sum, *value)
-			}
-		}
-		mutex.Lock()
-		newPayment = append(newPayment, sum...)
-		mutex.Unlock()
-	}()
-	wg.Wait()
-	if newPayment == nil {
-		return nil, ErrAccountNotFound
-	}
-	return
+	return s.FilterPaymentsContext(context.Background(), func(payment types.Payment) bool {
+		return payment.AccountID == accountID
+	}, ScanOptions{Workers: goroutines})
 }
-//SumPaymentsWithProgress
-func (s *Service) SumPaymentsWithProgress() <-chan Progress { 
 
-	ch := make(chan Progress,1)
-	defer close(ch)
-	// if err!= nil {
-	// 	return err
-	// }
-	// defer close(ch)
-	// if payment == nil {
-	// 	return ch
-	// }
-	// prog :=Progress{}
-	// if payment == nil {
-	// 	// sum := Progress{} 			
-	// 	// ch<- sum
-	// 	// <- ch
-	// 	// close(ch)
-	// 	return ch
-	// }
-	if s.payments == nil {
-		return ch
-	}
-	
-	// channel:=make([]<-chan int, parts)
-	// goroutines:=1
-	// i:=0
-	// mu := sync.Mutex{}
-	wg := sync.WaitGroup{}	
-	wg.Add(1)
-		// if lenPayment < size {
-		// 	payments = payment
-		// 	log.Print("payments ", payments)
-		// } else {
-		// }
-		go func(ch chan Progress){
-				defer wg.Done()
-				sum:=Progress{}
-
-				for _, value := range s.payments{
-					sum.Result+=value.Amount
-				}	
-					// sum.Part = i
-					// prog.Result = sum
-					ch<- sum
-				
-		}(ch)
-
-	// wg.Add(1)
-	// go func(ch chan Progress, j int) {
-	// 	log.Println("start go")
-	// 	defer wg.Done()
-	// 	sum := Progress{}
-	// 	for _, v := range s.payments[j*size:] {
-	// 		sum.Result += v.Amount
-	// 	}
-	// 	log.Println("end go")
-	// 	ch <- sum
-	// }(ch, i)
-	// if lenPayment > size {
-	// 	wg.Add(1)
-	// 	payments := s.payments[i*size:]
-	// 	go func(ch chan Progress, data []*types.Payment) {
-	// 		defer wg.Done()
-	// 		val := Progress{}
-	// 		for _, v := range data {
-	// 			val.Result += v.Amount
-	// 		}
-	// 		val.Part = i
-	// 		ch <- val
-
-	// 	}(ch, payments)
-	// }
+//FilterPaymentsByFn - платежи, для которых filter возвращает true, в исходном порядке
+func (s *Service) FilterPaymentsByFn(filter func(payment types.Payment) bool, goroutines int) ([]types.Payment, error) {
+	return s.FilterPaymentsContext(context.Background(), filter, ScanOptions{Workers: goroutines})
+}
 
-		
-	wg.Wait()
+//SumPaymentsWithProgress - сумма неотменённых платежей, посчитанная по частям (по числу процессоров).
+//Результат каждой части приходит в канал по мере готовности, после последней части канал закрывается.
+func (s *Service) SumPaymentsWithProgress() <-chan Progress {
+	_, ch := s.SumPaymentsWithProgressContext(context.Background(), WorkersAuto)
 	return ch
- }
\ No newline at end of file
+}
@@ -5,10 +5,51 @@ import (
 	"reflect"
 	"testing"
 	"log"
+	"time"
+	"io/ioutil"
+	"path/filepath"
 	"github.com/Shahlojon/wallet/pkg/types"
 	"github.com/google/uuid"
 )
 
+//testClock - управляемые часы для тестов
+type testClock struct {
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2020, 11, 20, 14, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+//newTestServiceUserWithClock - newTestServiceUser на тестовых часах с аккаунтом
+//defaultTestAccountUser.phone, пополненным на balance
+func newTestServiceUserWithClock(t *testing.T, balance types.Money) (*testServiceUser, *testClock, *types.Account) {
+	t.Helper()
+	clock := newTestClock()
+	s := newTestServiceUser()
+	s.SetClock(clock.Now)
+	account := s.addTestAccount(t, defaultTestAccountUser.phone, balance)
+	return s, clock, account
+}
+
+//addTestAccount - addAccountUser без платежей, ошибка завершает тест
+func (s *testServiceUser) addTestAccount(t *testing.T, phone types.Phone, balance types.Money) *types.Account {
+	t.Helper()
+	account, _, err := s.addAccountUser(testAccountUser{phone: phone, balance: balance})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account
+}
+
 func TestService_RegisterAccount_unsuccess(t *testing.T) {
 	vc := Service{}
 
@@ -31,7 +72,7 @@ func TestService_RegisterAccount_unsuccess(t *testing.T) {
 
 func TestService_FindAccoundById_Method_NotFound(t *testing.T) {
 	svc := Service{}
-	svc.RegisterAccount("+9920000001")
+	svc.RegisterAccount("+992000000001")
 
 	account, err := svc.FindAccountByID(3)
 	if err == nil {
@@ -84,7 +125,7 @@ func TestService_FindPaymentByID_fail(t *testing.T) {
 
 func TestService_Reject_fail(t *testing.T) {
 	svc := Service{}
-	svc.RegisterAccount("+9920000001")
+	svc.RegisterAccount("+992000000001")
 
 	account, err := svc.FindAccountByID(1)
 	if err != nil {
@@ -150,7 +191,7 @@ func TestService_Reject_success(t *testing.T) {
 func TestService_Repeat_success_user(t *testing.T) {
 	//создаем сервис
 	s := newTestServiceUser()
-	s.RegisterAccount("+9922000000")
+	s.RegisterAccount("+992200000000")
 	account, err :=s.FindAccountByID(1)
 	if err != nil {
 		t.Error(err)
@@ -294,7 +335,7 @@ func TestService_FavoritePayment_success_user(t *testing.T) {
 	//создаем сервис
 	var s Service
 
-	account, err := s.RegisterAccount("+9922000000")
+	account, err := s.RegisterAccount("+992200000000")
 	if err != nil {
 		t.Errorf("method RegisterAccount return not nil error, account=>%v", account)
 		return
@@ -504,3 +545,101 @@ func TestService_SumPayments(b *testing.T) {
 	}
 }
 
+func TestService_Timestamps_success(t *testing.T) {
+	clock := newTestClock()
+	svc := &Service{}
+	svc.SetClock(clock.Now)
+
+	created := clock.Now()
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !account.CreatedAt.Equal(created) || !account.UpdatedAt.Equal(created) {
+		t.Errorf("RegisterAccount(): wrong timestamps, account = %v", account)
+	}
+
+	clock.Add(time.Hour)
+	err = svc.Deposit(account.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !account.CreatedAt.Equal(created) || !account.UpdatedAt.Equal(clock.Now()) {
+		t.Errorf("Deposit(): wrong timestamps, account = %v", account)
+	}
+
+	clock.Add(time.Hour)
+	paid := clock.Now()
+	payment, err := svc.Pay(account.ID, 10_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Add(time.Hour)
+	favorite, err := svc.FavoritePayment(payment.ID, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !favorite.CreatedAt.Equal(clock.Now()) {
+		t.Errorf("FavoritePayment(): wrong timestamps, favorite = %v", favorite)
+	}
+
+	clock.Add(time.Hour)
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !payment.CreatedAt.Equal(paid) || !payment.UpdatedAt.Equal(clock.Now()) {
+		t.Errorf("Reject(): wrong timestamps, payment = %v", payment)
+	}
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := imported.FindPaymentByID(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CreatedAt.Equal(payment.CreatedAt) || !got.UpdatedAt.Equal(payment.UpdatedAt) {
+		t.Errorf("Import(): timestamps lost, want => %v got => %v", payment, got)
+	}
+}
+
+func TestService_Import_legacyDump(t *testing.T) {
+	dir := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(dir, "accounts.dump"), []byte("1;+992000000001;29000000|"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "payments.dump"), []byte("b555632e;1;1000000;auto;INPROGRESS|"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &Service{}
+	err = svc.Import(dir)
+	if err != nil {
+		t.Fatalf("Import(): error = %v", err)
+	}
+	account, err := svc.FindAccountByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 29000000 || !account.CreatedAt.IsZero() {
+		t.Errorf("Import(): wrong account = %v", account)
+	}
+	payment, err := svc.FindPaymentByID("b555632e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !payment.CreatedAt.IsZero() {
+		t.Errorf("Import(): wrong payment = %v", payment)
+	}
+}
@@ -0,0 +1,278 @@
+package wallet
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+var ErrDayNotOver = errors.New("day is not over yet")
+
+//ErrInvalidStatement - строку выписки нельзя разобрать
+type ErrInvalidStatement struct {
+	Line   int //Номер строки файла, начиная с 1
+	Reason string
+}
+
+func (e *ErrInvalidStatement) Error() string {
+	return fmt.Sprintf("invalid statement line %d: %s", e.Line, e.Reason)
+}
+
+//SettlementTotal - итог по одной группе платежей за день
+type SettlementTotal struct {
+	Key   string
+	Count int
+	Total types.Money
+}
+
+//DaySettlement - итоги дня. ByStatus считается по всем платежам дня,
+//ByCategory и ByMerchant - только по проведённым (OK).
+type DaySettlement struct {
+	Day        string //Дата в формате 2006-01-02
+	Completed  int    //Сколько платежей провело закрытие дня
+	ByStatus   []SettlementTotal
+	ByCategory []SettlementTotal
+	ByMerchant []SettlementTotal //Ключ - ID продавца; платежи без продавца не учитываются
+}
+
+//dayBounds - начало дня day и начало следующего дня
+func dayBounds(day time.Time) (time.Time, time.Time) {
+	year, month, date := day.Date()
+	start := time.Date(year, month, date, 0, 0, 0, 0, day.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+//inDay - платёж создан в пределах [start, end)
+func inDay(payment *types.Payment, start time.Time, end time.Time) bool {
+	return !payment.CreatedAt.Before(start) && payment.CreatedAt.Before(end)
+}
+
+//CloseDay - закрытие дня: все платежи дня в статусе INPROGRESS проводятся (Complete),
+//после чего считаются итоги. Закрыть можно только закончившийся день.
+func (s *Service) CloseDay(day time.Time) (*DaySettlement, error) {
+	start, end := dayBounds(day)
+	if end.After(s.now()) {
+		return nil, ErrDayNotOver
+	}
+
+	//Complete добавляет записи кешбэка, поэтому проходим только по уже существующим платежам;
+	//комиссии проводятся вместе со своими платежами
+	completed := 0
+	payments := s.payments
+	for _, payment := range payments {
+		if payment.Status != types.PaymentStatusInProgress || !inDay(payment, start, end) || isFee(payment) {
+			continue
+		}
+		_, err := s.Complete(payment.ID)
+		if err != nil {
+			return nil, err
+		}
+		completed++
+	}
+
+	settlement := s.DaySettlement(day)
+	settlement.Completed = completed
+	return settlement, nil
+}
+
+//DaySettlement - итоги дня без закрытия
+func (s *Service) DaySettlement(day time.Time) *DaySettlement {
+	start, end := dayBounds(day)
+	categories := s.categoryIndex()
+	byStatus := map[string]*SettlementTotal{}
+	byCategory := map[string]*SettlementTotal{}
+	byMerchant := map[string]*SettlementTotal{}
+	add := func(totals map[string]*SettlementTotal, key string, amount types.Money) {
+		total, ok := totals[key]
+		if !ok {
+			total = &SettlementTotal{Key: key}
+			totals[key] = total
+		}
+		total.Count++
+		total.Total += amount
+	}
+
+	for _, payment := range s.payments {
+		if !inDay(payment, start, end) {
+			continue
+		}
+		add(byStatus, string(payment.Status), payment.Amount)
+		if payment.Status != types.PaymentStatusOk {
+			continue
+		}
+		add(byCategory, string(canonicalCategory(categories, payment.Category)), payment.Amount)
+		if payment.MerchantID != 0 {
+			add(byMerchant, strconv.FormatInt(payment.MerchantID, 10), payment.Amount)
+		}
+	}
+
+	return &DaySettlement{
+		Day:        start.Format("2006-01-02"),
+		ByStatus:   sortedTotals(byStatus),
+		ByCategory: sortedTotals(byCategory),
+		ByMerchant: sortedTotals(byMerchant),
+	}
+}
+
+//sortedTotals - итоги по возрастанию ключа
+func sortedTotals(totals map[string]*SettlementTotal) []SettlementTotal {
+	sorted := make([]SettlementTotal, 0, len(totals))
+	for _, total := range totals {
+		sorted = append(sorted, *total)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	return sorted
+}
+
+//StatementLine - строка внешней выписки
+type StatementLine struct {
+	PaymentID string
+	Amount    types.Money
+	Status    types.PaymentStatus
+}
+
+//ParseStatement - читает выписку в формате CSV. Первая строка - заголовок, в котором должны быть
+//колонки id, amount и status (в любом порядке, остальные колонки игнорируются). Сумма - в дирамах.
+func ParseStatement(r io.Reader) ([]StatementLine, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, &ErrInvalidStatement{Line: 1, Reason: "missing header"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{"id": -1, "amount": -1, "status": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; ok {
+			columns[name] = i
+		}
+	}
+	for name, i := range columns {
+		if i < 0 {
+			return nil, &ErrInvalidStatement{Line: 1, Reason: "missing column " + name}
+		}
+	}
+
+	lines := []StatementLine{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) <= columns["id"] || len(record) <= columns["amount"] || len(record) <= columns["status"] {
+			return nil, &ErrInvalidStatement{Line: line, Reason: "not enough columns"}
+		}
+		amount, err := strconv.ParseInt(strings.TrimSpace(record[columns["amount"]]), 10, 64)
+		if err != nil {
+			return nil, &ErrInvalidStatement{Line: line, Reason: "invalid amount"}
+		}
+		lines = append(lines, StatementLine{
+			PaymentID: strings.TrimSpace(record[columns["id"]]),
+			Amount:    types.Money(amount),
+			Status:    types.PaymentStatus(strings.ToUpper(strings.TrimSpace(record[columns["status"]]))),
+		})
+	}
+	return lines, nil
+}
+
+//ReconciliationItem - результат сверки одного платежа. Для отсутствующих записей
+//соответствующие поля пустые.
+type ReconciliationItem struct {
+	PaymentID       string
+	WalletAmount    types.Money
+	WalletStatus    types.PaymentStatus
+	StatementAmount types.Money
+	StatementStatus types.PaymentStatus
+	Reason          string //Для расхождений: amount, status или duplicate
+}
+
+//Reconciliation - результат сверки дня с выпиской
+type Reconciliation struct {
+	Matched            []ReconciliationItem
+	MissingInWallet    []ReconciliationItem //Есть в выписке, но нет в кошельке
+	MissingInStatement []ReconciliationItem //Проведены в кошельке за день, но нет в выписке
+	Mismatched         []ReconciliationItem
+}
+
+//movedMoney - статусы, при которых деньги списаны или зачислены окончательно
+func movedMoney(status types.PaymentStatus) bool {
+	switch status {
+	case types.PaymentStatusOk, types.PaymentStatusInProgress, types.PaymentStatusRefund, types.PaymentStatusCashback:
+		return true
+	}
+	return false
+}
+
+//Reconcile - сверяет платежи дня с внешней выпиской по ID платежа.
+//Неуспешных, отменённых и зарезервированных платежей в выписке может не быть.
+func (s *Service) Reconcile(day time.Time, statement []StatementLine) Reconciliation {
+	start, end := dayBounds(day)
+	result := Reconciliation{
+		Matched:            []ReconciliationItem{},
+		MissingInWallet:    []ReconciliationItem{},
+		MissingInStatement: []ReconciliationItem{},
+		Mismatched:         []ReconciliationItem{},
+	}
+
+	payments := make(map[string]*types.Payment, len(s.payments))
+	for _, payment := range s.payments {
+		payments[payment.ID] = payment
+	}
+	seen := map[string]bool{}
+	for _, line := range statement {
+		item := ReconciliationItem{
+			PaymentID:       line.PaymentID,
+			StatementAmount: line.Amount,
+			StatementStatus: line.Status,
+		}
+		payment, ok := payments[line.PaymentID]
+		if !ok {
+			result.MissingInWallet = append(result.MissingInWallet, item)
+			continue
+		}
+		item.WalletAmount = payment.Amount
+		item.WalletStatus = payment.Status
+
+		switch {
+		case seen[payment.ID]:
+			item.Reason = "duplicate"
+		case payment.Amount != line.Amount:
+			item.Reason = "amount"
+		case payment.Status != line.Status:
+			item.Reason = "status"
+		}
+		seen[payment.ID] = true
+		if item.Reason != "" {
+			result.Mismatched = append(result.Mismatched, item)
+			continue
+		}
+		result.Matched = append(result.Matched, item)
+	}
+
+	for _, payment := range s.payments {
+		if seen[payment.ID] || !inDay(payment, start, end) || !movedMoney(payment.Status) {
+			continue
+		}
+		result.MissingInStatement = append(result.MissingInStatement, ReconciliationItem{
+			PaymentID:    payment.ID,
+			WalletAmount: payment.Amount,
+			WalletStatus: payment.Status,
+		})
+	}
+	return result
+}
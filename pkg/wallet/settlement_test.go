@@ -0,0 +1,156 @@
+package wallet
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func TestService_CloseDay(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 1_000_00)
+	merchant := svc.addTestMerchant(t)
+	svc.SetFeeRule(types.FeeRule{Category: "food", Fixed: 1_00})
+	day := clock.Now()
+
+	_, err := svc.PayMerchant(account.ID, merchant.ID, 10_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected, err := svc.Pay(account.ID, 20_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Reject(rejected.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Pay(account.ID, 30_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.CloseDay(day)
+	if err != ErrDayNotOver {
+		t.Errorf("CloseDay(): must return ErrDayNotOver, returned = %v", err)
+	}
+	clock.Add(10 * time.Hour)
+	_, err = svc.Pay(account.ID, 40_00, "auto") //следующий день не закрывается
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := svc.CloseDay(day)
+	if err != nil {
+		t.Fatalf("CloseDay(): error = %v", err)
+	}
+	want := &DaySettlement{
+		Day:       "2020-11-20",
+		Completed: 2,
+		ByStatus: []SettlementTotal{
+			{Key: "FAIL", Count: 1, Total: 20_00},
+			{Key: "OK", Count: 3, Total: 41_00},
+		},
+		ByCategory: []SettlementTotal{
+			{Key: "auto", Count: 1, Total: 30_00},
+			{Key: "fee", Count: 1, Total: 1_00},
+			{Key: "food", Count: 1, Total: 10_00},
+		},
+		ByMerchant: []SettlementTotal{
+			{Key: "1", Count: 1, Total: 10_00},
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("CloseDay(): want => %v got => %v", want, got)
+	}
+	if svc.payments[len(svc.payments)-1].Status != types.PaymentStatusInProgress {
+		t.Errorf("CloseDay(): next day payment must stay in progress")
+	}
+}
+
+func TestParseStatement(t *testing.T) {
+	got, err := ParseStatement(strings.NewReader("Status,ID,Amount,Note\nok,a,100,first\nFAIL, b ,200,\n"))
+	if err != nil {
+		t.Fatalf("ParseStatement(): error = %v", err)
+	}
+	want := []StatementLine{
+		{PaymentID: "a", Amount: 100, Status: types.PaymentStatusOk},
+		{PaymentID: "b", Amount: 200, Status: types.PaymentStatusFail},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("ParseStatement(): want => %v got => %v", want, got)
+	}
+
+	tests := []struct {
+		content string
+		line    int
+	}{
+		{"", 1},
+		{"id,amount\n", 1},
+		{"id,amount,status\na,100,OK\nb,1.5,OK\n", 3},
+	}
+	for _, test := range tests {
+		_, err := ParseStatement(strings.NewReader(test.content))
+		statementErr := &ErrInvalidStatement{}
+		if !errors.As(err, &statementErr) || statementErr.Line != test.line {
+			t.Errorf("ParseStatement(%q): must return ErrInvalidStatement at line %v, returned = %v", test.content, test.line, err)
+		}
+	}
+}
+
+func TestService_Reconcile(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 1_000_00)
+	svc.addTestMerchant(t)
+	day := clock.Now()
+	pay := func(amount types.Money) *types.Payment {
+		payment, err := svc.Pay(account.ID, amount, "auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payment
+	}
+	matched := pay(10_00)
+	wrongAmount := pay(20_00)
+	wrongStatus := pay(30_00)
+	missing := pay(40_00)
+	rejected := pay(50_00)
+	err := svc.Reject(rejected.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(10 * time.Hour)
+	_, err = svc.CloseDay(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := svc.Reconcile(day, []StatementLine{
+		{PaymentID: matched.ID, Amount: 10_00, Status: types.PaymentStatusOk},
+		{PaymentID: wrongAmount.ID, Amount: 25_00, Status: types.PaymentStatusOk},
+		{PaymentID: wrongStatus.ID, Amount: 30_00, Status: types.PaymentStatusFail},
+		{PaymentID: matched.ID, Amount: 10_00, Status: types.PaymentStatusOk},
+		{PaymentID: "unknown", Amount: 1_00, Status: types.PaymentStatusOk},
+	})
+	want := Reconciliation{
+		Matched: []ReconciliationItem{
+			{PaymentID: matched.ID, WalletAmount: 10_00, WalletStatus: "OK", StatementAmount: 10_00, StatementStatus: "OK"},
+		},
+		MissingInWallet: []ReconciliationItem{
+			{PaymentID: "unknown", StatementAmount: 1_00, StatementStatus: "OK"},
+		},
+		MissingInStatement: []ReconciliationItem{
+			{PaymentID: missing.ID, WalletAmount: 40_00, WalletStatus: "OK"},
+		},
+		Mismatched: []ReconciliationItem{
+			{PaymentID: wrongAmount.ID, WalletAmount: 20_00, WalletStatus: "OK", StatementAmount: 25_00, StatementStatus: "OK", Reason: "amount"},
+			{PaymentID: wrongStatus.ID, WalletAmount: 30_00, WalletStatus: "OK", StatementAmount: 30_00, StatementStatus: "FAIL", Reason: "status"},
+			{PaymentID: matched.ID, WalletAmount: 10_00, WalletStatus: "OK", StatementAmount: 10_00, StatementStatus: "OK", Reason: "duplicate"},
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Reconcile(): want => %v got => %v", want, got)
+	}
+}
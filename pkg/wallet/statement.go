@@ -0,0 +1,260 @@
+package wallet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+//StatementEntryKind представляет собой вид строки выписки по счёту
+type StatementEntryKind string
+
+//Виды строк выписки
+const (
+	StatementDeposit     StatementEntryKind = "deposit"
+	StatementPayment     StatementEntryKind = "payment"
+	StatementRefund      StatementEntryKind = "refund"
+	StatementFee         StatementEntryKind = "fee"
+	StatementCashback    StatementEntryKind = "cashback"
+	StatementClawback    StatementEntryKind = "clawback"
+	StatementReversal    StatementEntryKind = "reversal" //Возврат отменённого платежа или комиссии
+	StatementTransferIn  StatementEntryKind = "transfer_in"
+	StatementTransferOut StatementEntryKind = "transfer_out"
+)
+
+//StatementEntry - движение средств по счёту
+type StatementEntry struct {
+	Time     time.Time
+	Kind     StatementEntryKind
+	ID       string //ID платежа или пополнения
+	Category types.PaymentCategory
+	Amount   types.Money //Поступления положительные, списания отрицательные
+	Balance  types.Money //Баланс после движения
+}
+
+//Statement - выписка по счёту за период
+type Statement struct {
+	AccountID int64
+	Phone     types.Phone
+	From      time.Time
+	To        time.Time
+	Opening   types.Money //Баланс на начало периода
+	Closing   types.Money //Баланс на конец периода
+	Credits   types.Money //Сумма поступлений за период
+	Debits    types.Money //Сумма списаний за период, положительная
+	Entries   []StatementEntry
+}
+
+//movements - все движения средств по счёту в порядке времени. Резервы (Authorize)
+//баланс не меняют и в движения не попадают; отмена платежа даёт отдельное движение.
+func (s *Service) movements(accountID int64) []StatementEntry {
+	entries := []StatementEntry{}
+	for _, deposit := range s.deposits {
+		if deposit.AccountID == accountID {
+			entries = append(entries, StatementEntry{
+				Time: deposit.CreatedAt, Kind: StatementDeposit, ID: deposit.ID, Amount: deposit.Amount,
+			})
+		}
+	}
+
+	for _, payment := range s.payments {
+		entry := StatementEntry{Time: payment.CreatedAt, ID: payment.ID, Category: payment.Category}
+		if payment.ToAccountID == accountID {
+			entry.Kind, entry.Amount = StatementTransferIn, payment.Amount
+			entries = append(entries, entry)
+			continue
+		}
+		if payment.AccountID != accountID {
+			continue
+		}
+
+		switch {
+		case payment.Status == types.PaymentStatusAuthorized || payment.Status == types.PaymentStatusVoid:
+			continue
+		case payment.Status == types.PaymentStatusRefund:
+			entry.Kind, entry.Amount = StatementRefund, payment.Amount
+		case payment.Status == types.PaymentStatusCashback || payment.Status == types.PaymentStatusClawback:
+			entry.Kind, entry.Amount = StatementCashback, payment.Amount
+		case isFee(payment):
+			entry.Kind, entry.Amount = StatementFee, -payment.Amount
+		case payment.ToAccountID != 0:
+			entry.Kind, entry.Amount = StatementTransferOut, -payment.Amount
+		default:
+			entry.Kind, entry.Amount = StatementPayment, -payment.Amount
+		}
+		entries = append(entries, entry)
+
+		reverse := StatementEntry{Time: payment.UpdatedAt, ID: payment.ID, Category: payment.Category}
+		switch {
+		case payment.Status == types.PaymentStatusClawback:
+			reverse.Kind, reverse.Amount = StatementClawback, -payment.Amount
+		case payment.Status == types.PaymentStatusFail && entry.Kind == StatementFee:
+			reverse.Kind, reverse.Amount = StatementReversal, payment.Amount
+		case payment.Status == types.PaymentStatusFail:
+			//частичные возвраты уже зачислены отдельными движениями
+			reverse.Kind, reverse.Amount = StatementReversal, payment.Amount-s.refunded(payment.ID)
+		default:
+			continue
+		}
+		entries = append(entries, reverse)
+	}
+
+	//при равном времени пополнения идут раньше платежей, а отмена - после отменённого платежа
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries
+}
+
+//Statement - выписка по счёту за период [from, to]; нулевая граница не ограничивает период.
+//Баланс на начало периода считается от текущего баланса назад, поэтому учитывает
+//и средства, история которых не сохранилась (например, загруженные из старых дампов).
+func (s *Service) Statement(accountID int64, from time.Time, to time.Time) (*Statement, error) {
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return nil, ErrInvalidQuery
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{
+		AccountID: account.ID,
+		Phone:     account.Phone,
+		From:      from,
+		To:        to,
+		Opening:   account.Balance,
+		Entries:   []StatementEntry{},
+	}
+	movements := s.movements(accountID)
+	for _, entry := range movements {
+		if from.IsZero() || !entry.Time.Before(from) {
+			statement.Opening -= entry.Amount
+		}
+	}
+
+	balance := statement.Opening
+	for _, entry := range movements {
+		if !from.IsZero() && entry.Time.Before(from) || !to.IsZero() && entry.Time.After(to) {
+			continue
+		}
+		balance += entry.Amount
+		entry.Balance = balance
+		if entry.Amount > 0 {
+			statement.Credits += entry.Amount
+		} else {
+			statement.Debits -= entry.Amount
+		}
+		statement.Entries = append(statement.Entries, entry)
+	}
+	statement.Closing = balance
+	return statement, nil
+}
+
+//formatMoney - сумма в дирамах в виде 1234.05
+func formatMoney(amount types.Money) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+//formatPeriodBound - граница периода выписки; нулевая граница не ограничивает период
+func formatPeriodBound(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+//WriteText - выписка в виде текстовой таблицы
+func (st *Statement) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Account %d (%s)\n", st.AccountID, st.Phone)
+	fmt.Fprintf(tw, "Period %s - %s\n", formatPeriodBound(st.From), formatPeriodBound(st.To))
+	fmt.Fprintf(tw, "Opening balance\t\t\t\t%s\n", formatMoney(st.Opening))
+	fmt.Fprintln(tw, "TIME\tKIND\tCATEGORY\tAMOUNT\tBALANCE")
+	for _, entry := range st.Entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", entry.Time.Format("2006-01-02 15:04"),
+			entry.Kind, entry.Category, formatMoney(entry.Amount), formatMoney(entry.Balance))
+	}
+	fmt.Fprintf(tw, "Credits\t\t\t%s\t\n", formatMoney(st.Credits))
+	fmt.Fprintf(tw, "Debits\t\t\t%s\t\n", formatMoney(st.Debits))
+	fmt.Fprintf(tw, "Closing balance\t\t\t\t%s\n", formatMoney(st.Closing))
+	return tw.Flush()
+}
+
+//WriteCSV - выписка в формате CSV: строка заголовка, строки движений. Начальный и конечный
+//баланс - строки с видом opening и closing. Суммы в дирамах.
+func (st *Statement) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	records := [][]string{
+		{"time", "kind", "id", "category", "amount", "balance"},
+		{formatCSVTime(st.From), "opening", "", "", "", strconv.FormatInt(int64(st.Opening), 10)},
+	}
+	for _, entry := range st.Entries {
+		records = append(records, []string{
+			formatCSVTime(entry.Time),
+			string(entry.Kind),
+			entry.ID,
+			string(entry.Category),
+			strconv.FormatInt(int64(entry.Amount), 10),
+			strconv.FormatInt(int64(entry.Balance), 10),
+		})
+	}
+	records = append(records, []string{formatCSVTime(st.To), "closing", "", "", "", strconv.FormatInt(int64(st.Closing), 10)})
+	return writer.WriteAll(records)
+}
+
+//formatCSVTime - время в RFC3339, нулевое время - пустая строка
+func formatCSVTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+//statementHTML - шаблон выписки; стили встроены, чтобы файл открывался без внешних ресурсов
+var statementHTML = template.Must(template.New("statement").Funcs(template.FuncMap{
+	"money":  formatMoney,
+	"period": formatPeriodBound,
+	"time":   func(t time.Time) string { return t.Format("2006-01-02 15:04") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Statement {{.AccountID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; }
+td.amount { text-align: right; }
+tr.total td { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Account {{.AccountID}} ({{.Phone}})</h1>
+<p>Period {{period .From}} - {{period .To}}</p>
+<table>
+<tr><th>Time</th><th>Kind</th><th>Category</th><th>Amount</th><th>Balance</th></tr>
+<tr class="total"><td colspan="4">Opening balance</td><td class="amount">{{money .Opening}}</td></tr>
+{{range .Entries}}<tr><td>{{time .Time}}</td><td>{{.Kind}}</td><td>{{.Category}}</td><td class="amount">{{money .Amount}}</td><td class="amount">{{money .Balance}}</td></tr>
+{{end}}<tr class="total"><td colspan="3">Credits</td><td class="amount">{{money .Credits}}</td><td></td></tr>
+<tr class="total"><td colspan="3">Debits</td><td class="amount">{{money .Debits}}</td><td></td></tr>
+<tr class="total"><td colspan="4">Closing balance</td><td class="amount">{{money .Closing}}</td></tr>
+</table>
+</body>
+</html>
+`))
+
+//WriteHTML - выписка в виде самодостаточной HTML-страницы
+func (st *Statement) WriteHTML(w io.Writer) error {
+	return statementHTML.Execute(w, st)
+}
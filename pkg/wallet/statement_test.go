@@ -0,0 +1,189 @@
+package wallet
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+//addTestStatementHistory - история для выписки после пополнения на 100 сомони: платёж с комиссией,
+//частичный возврат и отмена этого платежа, ещё один платёж и пополнение, с интервалом в час
+func (s *testServiceUser) addTestStatementHistory(t *testing.T, clock *testClock, account *types.Account) {
+	t.Helper()
+	s.SetFeeRule(types.FeeRule{Category: "auto", Fixed: 1_00})
+
+	clock.Add(time.Hour)
+	payment, err := s.Pay(account.ID, 30_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(time.Hour)
+	_, err = s.Refund(payment.ID, 5_00, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(time.Hour)
+	err = s.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(time.Hour)
+	_, err = s.Pay(account.ID, 10_00, "food")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(time.Hour)
+	err = s.Deposit(account.ID, 20_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestService_Statement(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 100_00)
+	svc.addTestStatementHistory(t, clock, account)
+	start := newTestClock().Now()
+
+	got, err := svc.Statement(account.ID, start.Add(90*time.Minute), start.Add(4*time.Hour))
+	if err != nil {
+		t.Fatalf("Statement(): error = %v", err)
+	}
+	if got.Opening != 69_00 || got.Closing != 90_00 || got.Credits != 31_00 || got.Debits != 10_00 {
+		t.Errorf("Statement(): wrong totals = %v", got)
+	}
+	kinds := []StatementEntryKind{}
+	balances := []types.Money{}
+	for _, entry := range got.Entries {
+		kinds = append(kinds, entry.Kind)
+		balances = append(balances, entry.Balance)
+	}
+	wantKinds := []StatementEntryKind{StatementRefund, StatementReversal, StatementReversal, StatementPayment}
+	wantBalances := []types.Money{74_00, 99_00, 100_00, 90_00}
+	if !reflect.DeepEqual(wantKinds, kinds) || !reflect.DeepEqual(wantBalances, balances) {
+		t.Errorf("Statement(): want => %v %v got => %v %v", wantKinds, wantBalances, kinds, balances)
+	}
+
+	all, err := svc.Statement(account.ID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.Opening != 0 || all.Closing != account.Balance || len(all.Entries) != 8 {
+		t.Errorf("Statement(): whole history must end with current balance = %v", all)
+	}
+
+	_, err = svc.Statement(account.ID, start, start.Add(-time.Hour))
+	if err != ErrInvalidQuery {
+		t.Errorf("Statement(): must return ErrInvalidQuery, returned = %v", err)
+	}
+}
+
+func TestService_Statement_importedBalance(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 100_00)
+	svc.addTestStatementHistory(t, clock, account)
+	//баланс из старого дампа, пополнений по нему нет
+	svc.deposits = nil
+
+	got, err := svc.Statement(account.ID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Opening != 120_00 || got.Closing != account.Balance {
+		t.Errorf("Statement(): unknown history must go to opening balance = %v", got)
+	}
+}
+
+func TestStatement_render(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 100_00)
+	svc.addTestStatementHistory(t, clock, account)
+	statement, err := svc.Statement(account.ID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := &bytes.Buffer{}
+	err = statement.WriteText(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "Closing balance") || !strings.Contains(text.String(), "-30.00") {
+		t.Errorf("WriteText(): wrong text = %v", text)
+	}
+
+	csvText := &bytes.Buffer{}
+	err = statement.WriteCSV(csvText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvText.String()), "\n")
+	if len(lines) != len(statement.Entries)+3 || lines[len(lines)-1] != ",closing,,,,11000" {
+		t.Errorf("WriteCSV(): wrong csv = %v", csvText)
+	}
+
+	html := &bytes.Buffer{}
+	err = statement.WriteHTML(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "<style>") || !strings.Contains(html.String(), "90.00") ||
+		strings.Contains(html.String(), "<link") {
+		t.Errorf("WriteHTML(): page must be self-contained = %v", html)
+	}
+}
+
+func TestService_Deposits_exportImport(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 100_00)
+	svc.addTestStatementHistory(t, clock, account)
+
+	dir := t.TempDir()
+	err := svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := svc.FindDeposits(account.ID)
+	got := imported.FindDeposits(account.ID)
+	if len(got) != 2 || got[0].ID != want[0].ID || got[1].Amount != 20_00 || !got[1].CreatedAt.Equal(want[1].CreatedAt) {
+		t.Errorf("Import(): want => %v got => %v", want, got)
+	}
+}
+
+func TestService_Deposits_restoreAt(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 100_00)
+	dir := t.TempDir()
+	//ранний снимок, от которого журнал проигрывается на момент в начале истории
+	_, err := svc.Snapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.addTestStatementHistory(t, clock, account)
+	_, err = svc.Snapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//снимок уже содержит пополнения - повторное применение журнала их не дублирует
+	restored, err := RestoreAt(dir, clock.Now())
+	if err != nil {
+		t.Fatalf("RestoreAt(): error = %v", err)
+	}
+	if got := restored.FindDeposits(account.ID); len(got) != 2 {
+		t.Errorf("RestoreAt(): wrong deposits = %v", got)
+	}
+
+	restored, err = RestoreAt(dir, newTestClock().Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("RestoreAt(): error = %v", err)
+	}
+	statement, err := restored.Statement(account.ID, time.Time{}, time.Time{})
+	if err != nil || len(statement.Entries) != 1 || statement.Closing != 100_00 {
+		t.Errorf("Statement(): restored from journal = %v, error = %v", statement, err)
+	}
+}
@@ -0,0 +1,212 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+var ErrInvalidTier = errors.New("invalid verification tier")
+var ErrTierNotUpgrade = errors.New("new tier must be higher than current")
+var ErrProfileIncomplete = errors.New("name and document number are required for verification")
+var ErrInvalidProfile = errors.New("profile fields must not contain ';' or '|'")
+
+//tierRanks - порядок уровней идентификации
+var tierRanks = map[types.VerificationTier]int{
+	types.TierUnverified: 0,
+	types.TierBasic:      1,
+	types.TierFull:       2,
+}
+
+//TierLimitKind представляет собой вид ограничения уровня идентификации
+type TierLimitKind string
+
+//Виды ограничений уровня идентификации
+const (
+	TierLimitMaxBalance      TierLimitKind = "max balance"
+	TierLimitMonthlyTurnover TierLimitKind = "monthly turnover"
+)
+
+//ErrTierLimitExceeded - операция превысит ограничения уровня идентификации кошелька
+type ErrTierLimitExceeded struct {
+	AccountID int64
+	Tier      types.VerificationTier
+	Kind      TierLimitKind
+	Limit     types.Money
+	Current   types.Money //Текущий баланс или оборот за месяц
+	Amount    types.Money //Сумма отклонённой операции
+}
+
+func (e *ErrTierLimitExceeded) Error() string {
+	return fmt.Sprintf("%s limit of %s wallet %d exceeded: limit %d, current %d, amount %d; upgrade verification tier to raise it",
+		e.Kind, e.Tier, e.AccountID, e.Limit, e.Current, e.Amount)
+}
+
+//tierOf - уровень идентификации аккаунта, пустой считается UNVERIFIED
+func tierOf(account *types.Account) types.VerificationTier {
+	if account.Profile.Tier == "" {
+		return types.TierUnverified
+	}
+	return account.Profile.Tier
+}
+
+//SetTierLimits - задаёт ограничения для всех кошельков уровня tier
+func (s *Service) SetTierLimits(tier types.VerificationTier, limits types.TierLimits) error {
+	if _, ok := tierRanks[tier]; !ok {
+		return ErrInvalidTier
+	}
+	if limits.MaxBalance < 0 || limits.MonthlyTurnover < 0 {
+		return ErrAmountMustBePositive
+	}
+	if s.tierLimits == nil {
+		s.tierLimits = map[types.VerificationTier]types.TierLimits{}
+	}
+	s.tierLimits[tier] = limits
+	return nil
+}
+
+//FindTierLimits - ограничения уровня; если они не заданы - нулевое значение (без ограничений)
+func (s *Service) FindTierLimits(tier types.VerificationTier) types.TierLimits {
+	return s.tierLimits[tier]
+}
+
+//SetProfile - задаёт имя и номер документа владельца счёта, уровень идентификации не меняется
+func (s *Service) SetProfile(accountID int64, name string, documentNumber string) error {
+	name, documentNumber = strings.TrimSpace(name), strings.TrimSpace(documentNumber)
+	if strings.ContainsAny(name+documentNumber, ";|") {
+		return ErrInvalidProfile
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+
+	account.Profile.Name = name
+	account.Profile.DocumentNumber = documentNumber
+	account.UpdatedAt = s.now()
+	s.recordAccount(OperationProfile, account)
+	return nil
+}
+
+//UpgradeTier - повышает уровень идентификации. Для любого уровня выше UNVERIFIED
+//в профиле должны быть имя и номер документа.
+func (s *Service) UpgradeTier(accountID int64, tier types.VerificationTier) error {
+	rank, ok := tierRanks[tier]
+	if !ok {
+		return ErrInvalidTier
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+	if rank <= tierRanks[tierOf(account)] {
+		return ErrTierNotUpgrade
+	}
+	if account.Profile.Name == "" || account.Profile.DocumentNumber == "" {
+		return ErrProfileIncomplete
+	}
+
+	account.Profile.Tier = tier
+	account.UpdatedAt = s.now()
+	s.recordAccount(OperationUpgradeTier, account)
+	return nil
+}
+
+//monthTurnover - оборот аккаунта за текущий календарный месяц
+func (s *Service) monthTurnover(account *types.Account) types.Money {
+	if !account.TurnoverMonth.Equal(monthStart(s.now())) {
+		return 0
+	}
+	return account.Turnover
+}
+
+//addTurnover - учитывает операцию в обороте аккаунта за месяц
+func (s *Service) addTurnover(account *types.Account, amount types.Money) {
+	account.Turnover = s.monthTurnover(account) + amount
+	account.TurnoverMonth = monthStart(s.now())
+}
+
+//checkTier - проверяет, что зачисление credit и списание debit не выведут кошелёк
+//за ограничения его уровня идентификации
+func (s *Service) checkTier(account *types.Account, credit types.Money, debit types.Money) error {
+	tier := tierOf(account)
+	limits := s.tierLimits[tier]
+
+	if limits.MaxBalance != 0 && credit > 0 && account.Balance+credit > limits.MaxBalance {
+		return &ErrTierLimitExceeded{
+			AccountID: account.ID, Tier: tier, Kind: TierLimitMaxBalance,
+			Limit: limits.MaxBalance, Current: account.Balance, Amount: credit,
+		}
+	}
+	turnover := s.monthTurnover(account)
+	if limits.MonthlyTurnover != 0 && turnover+credit+debit > limits.MonthlyTurnover {
+		return &ErrTierLimitExceeded{
+			AccountID: account.ID, Tier: tier, Kind: TierLimitMonthlyTurnover,
+			Limit: limits.MonthlyTurnover, Current: turnover, Amount: credit + debit,
+		}
+	}
+	return nil
+}
+
+//monthStart - начало календарного месяца в часовом поясе t
+func monthStart(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
+
+//exportTierLimits - сохраняет ограничения уровней в dir/tiers.dump: уровень;баланс;оборот
+func (s *Service) exportTierLimits(dir string) error {
+	tiers := make([]string, 0, len(s.tierLimits))
+	for tier := range s.tierLimits {
+		tiers = append(tiers, string(tier))
+	}
+	sort.Strings(tiers)
+
+	records := make([]string, 0, len(tiers))
+	for _, tier := range tiers {
+		limits := s.tierLimits[types.VerificationTier(tier)]
+		records = append(records, tier+";"+strconv.FormatInt(int64(limits.MaxBalance), 10)+";"+
+			strconv.FormatInt(int64(limits.MonthlyTurnover), 10))
+	}
+	return writeDump(dir, "tiers.dump", records)
+}
+
+//importTierLimits - загружает ограничения уровней из dir/tiers.dump
+func (s *Service) importTierLimits(dir string) error {
+	values, err := readDump(dir, "tiers.dump")
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		if len(value) < 3 {
+			return ErrInvalidDump
+		}
+		maxBalance, err := strconv.ParseInt(value[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		turnover, err := strconv.ParseInt(value[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		err = s.SetTierLimits(types.VerificationTier(value[0]), types.TierLimits{
+			MaxBalance:      types.Money(maxBalance),
+			MonthlyTurnover: types.Money(turnover),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shahlojon/wallet/pkg/types"
+)
+
+func checkTierLimitExceeded(t *testing.T, err error, kind TierLimitKind) {
+	t.Helper()
+	tierErr := &ErrTierLimitExceeded{}
+	if !errors.As(err, &tierErr) {
+		t.Fatalf("must return ErrTierLimitExceeded, returned = %v", err)
+	}
+	if tierErr.Kind != kind {
+		t.Errorf("wrong tier limit, want => %v got => %v", kind, tierErr.Kind)
+	}
+}
+
+func TestService_Deposit_tierMaxBalance(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 0)
+	svc.SetTierLimits(types.TierUnverified, types.TierLimits{MaxBalance: 1_000_00, MonthlyTurnover: 1_500_00})
+	svc.SetTierLimits(types.TierFull, types.TierLimits{MaxBalance: 100_000_00})
+
+	err := svc.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatalf("Deposit(): error = %v", err)
+	}
+	err = svc.Deposit(account.ID, 1)
+	checkTierLimitExceeded(t, err, TierLimitMaxBalance)
+	if account.Balance != 1_000_00 {
+		t.Errorf("Deposit(): rejected deposit must not change balance = %v", account.Balance)
+	}
+}
+
+func TestService_Pay_tierMonthlyTurnover(t *testing.T) {
+	svc, clock, account := newTestServiceUserWithClock(t, 0)
+	svc.SetTierLimits(types.TierUnverified, types.TierLimits{MaxBalance: 1_000_00, MonthlyTurnover: 1_500_00})
+	svc.SetTierLimits(types.TierFull, types.TierLimits{MaxBalance: 100_000_00})
+
+	err := svc.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Pay(account.ID, 400_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Pay(account.ID, 100_01, "auto")
+	checkTierLimitExceeded(t, err, TierLimitMonthlyTurnover)
+	err = svc.Deposit(account.ID, 100_01)
+	checkTierLimitExceeded(t, err, TierLimitMonthlyTurnover)
+
+	//в новом месяце оборот считается заново
+	clock.Add(11 * 24 * time.Hour)
+	_, err = svc.Pay(account.ID, 500_00, "auto")
+	if err != nil {
+		t.Errorf("Pay(): next month error = %v", err)
+	}
+}
+
+func TestService_UpgradeTier_raisesLimits(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 0)
+	svc.SetTierLimits(types.TierUnverified, types.TierLimits{MaxBalance: 1_000_00, MonthlyTurnover: 1_500_00})
+	svc.SetTierLimits(types.TierFull, types.TierLimits{MaxBalance: 100_000_00})
+
+	err := svc.UpgradeTier(account.ID, types.TierFull)
+	if err != ErrProfileIncomplete {
+		t.Errorf("UpgradeTier(): must return ErrProfileIncomplete, returned = %v", err)
+	}
+	err = svc.SetProfile(account.ID, "Ali Valiev", "A1234567")
+	if err != nil {
+		t.Fatalf("SetProfile(): error = %v", err)
+	}
+	err = svc.UpgradeTier(account.ID, types.TierFull)
+	if err != nil {
+		t.Fatalf("UpgradeTier(): error = %v", err)
+	}
+	err = svc.UpgradeTier(account.ID, types.TierBasic)
+	if err != ErrTierNotUpgrade {
+		t.Errorf("UpgradeTier(): must return ErrTierNotUpgrade, returned = %v", err)
+	}
+	err = svc.UpgradeTier(account.ID, "GOLD")
+	if err != ErrInvalidTier {
+		t.Errorf("UpgradeTier(): must return ErrInvalidTier, returned = %v", err)
+	}
+
+	err = svc.Deposit(account.ID, 50_000_00)
+	if err != nil {
+		t.Errorf("Deposit(): after upgrade error = %v", err)
+	}
+}
+
+func TestService_Tiers_exportImport(t *testing.T) {
+	svc, _, account := newTestServiceUserWithClock(t, 0)
+	svc.SetTierLimits(types.TierUnverified, types.TierLimits{MaxBalance: 1_000_00, MonthlyTurnover: 1_500_00})
+	svc.SetTierLimits(types.TierFull, types.TierLimits{MaxBalance: 100_000_00})
+	svc.SetProfile(account.ID, "Ali Valiev", "A1234567")
+	svc.UpgradeTier(account.ID, types.TierBasic)
+	svc.Deposit(account.ID, 300_00)
+
+	dir := t.TempDir()
+	err := svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := imported.FindAccountByID(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Profile != account.Profile || got.Turnover != 300_00 || !got.TurnoverMonth.Equal(account.TurnoverMonth) {
+		t.Errorf("Import(): wrong account = %v", got)
+	}
+	if imported.FindTierLimits(types.TierUnverified) != svc.FindTierLimits(types.TierUnverified) ||
+		imported.FindTierLimits(types.TierFull) != svc.FindTierLimits(types.TierFull) {
+		t.Errorf("Import(): wrong tier limits")
+	}
+}